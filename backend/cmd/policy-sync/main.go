@@ -0,0 +1,92 @@
+package main
+
+import (
+	"backend/db"
+	"backend/middleware"
+	"backend/models"
+	"backend/policy"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"go.temporal.io/sdk/client"
+)
+
+func main() {
+	dir := flag.String("dir", "policies", "directory or git working tree holding the policy YAML")
+	modelPath := flag.String("model", "model.config", "Casbin model the policies are validated against")
+	dryRun := flag.Bool("dry-run", false, "print the changes without applying them")
+	pull := flag.Bool("pull", false, "git pull --ff-only before reading the policies")
+	schedule := flag.String("schedule", "", "cron schedule; when set, register a periodic Temporal sync instead of running once")
+	flag.Parse()
+
+	input := models.PolicySyncInput{
+		Dir:       *dir,
+		ModelPath: *modelPath,
+		Pull:      *pull,
+		DryRun:    *dryRun,
+	}
+
+	if *schedule != "" {
+		// The worker resolves the paths from its own working directory.
+		var err error
+		if input.Dir, err = filepath.Abs(input.Dir); err != nil {
+			log.Fatalf("invalid -dir: %v", err)
+		}
+		if input.ModelPath, err = filepath.Abs(input.ModelPath); err != nil {
+			log.Fatalf("invalid -model: %v", err)
+		}
+
+		c, err := client.Dial(client.Options{})
+		if err != nil {
+			log.Fatalf("unable to create Temporal client: %v", err)
+		}
+		defer c.Close()
+
+		we, err := c.ExecuteWorkflow(
+			context.Background(),
+			client.StartWorkflowOptions{
+				ID:           "policy-sync",
+				TaskQueue:    "POLICY_SYNC_QUEUE",
+				CronSchedule: *schedule,
+			},
+			"PolicySyncWorkflow",
+			input,
+		)
+		if err != nil {
+			log.Fatalf("failed to schedule policy sync: %v", err)
+		}
+		fmt.Println("Scheduled policy sync", we.GetID(), "with", *schedule)
+		return
+	}
+
+	if err := db.ConnectDB("mongodb://localhost:27017"); err != nil {
+		log.Fatalf("unable to connect to MongoDB: %v", err)
+	}
+	enforcer, err := middleware.InitCasbin()
+	if err != nil {
+		log.Fatalf("Casbin init failed: %v", err)
+	}
+
+	result, err := policy.Sync(context.Background(), enforcer, input)
+	if err != nil {
+		log.Fatalf("policy sync failed: %v", err)
+	}
+
+	if result.Revision != "" {
+		fmt.Println("Revision:", result.Revision)
+	}
+	for _, rule := range result.Added {
+		fmt.Println("+", rule)
+	}
+	for _, rule := range result.Removed {
+		fmt.Println("-", rule)
+	}
+	if len(result.Added)+len(result.Removed) == 0 {
+		fmt.Println("Policies are up to date")
+	} else if result.DryRun {
+		fmt.Println("Dry run; no changes applied")
+	}
+}
@@ -2,8 +2,10 @@ package db
 
 import (
 	"context"
+	"strings"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -25,9 +27,281 @@ func ConnectDB(uri string) error {
 	}
 
 	MongoClient = client
-	return nil
+	return EnsureIndexes(ctx)
+}
+
+// EnsureIndexes creates the indexes the handlers rely on. CreateMany is a
+// no-op for indexes that already exist with the same definition.
+func EnsureIndexes(ctx context.Context) error {
+	if err := backfillOrgSlugs(ctx); err != nil {
+		return err
+	}
+	if err := backfillOrgNames(ctx); err != nil {
+		return err
+	}
+
+	// name_ci used to be a plain index; it is replaced by the unique one below.
+	_, _ = GetOrgCollection().Indexes().DropOne(ctx, "name_ci")
+
+	_, err := GetOrgCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "slug", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "created_by", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}},
+			Options: options.Index().SetName("org_text"),
+		},
+		{Keys: bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "name", Value: 1}},
+			Options: options.Index().SetName(orgNameIndex).SetUnique(true).SetCollation(nameCollation),
+		},
+		{Keys: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "visibility", Value: 1}, {Key: "name", Value: 1}, {Key: "_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = GetMembershipCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "org_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+		{Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "role", Value: 1}}},
+		{Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "joined_at", Value: 1}, {Key: "_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = GetInviteCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = GetTeamCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "org_id", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true).SetCollation(nameCollation),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = GetTeamMemberCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "team_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "user_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// A domain can be claimed by several orgs but verified by only one.
+	_, err = GetDomainClaimCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "org_id", Value: 1}, {Key: "domain", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "domain", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("domain_verified").
+				SetPartialFilterExpression(bson.M{"verified": true}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = GetAutoJoinCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "org_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// A user can have only one pending request per org.
+	_, err = GetJoinRequestCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("join_request_pending").
+				SetPartialFilterExpression(bson.M{"status": "pending"}),
+		},
+		{Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = GetLastSeenCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "domain", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "domain", Value: 1}, {Key: "seen_at", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = GetOrgKeyCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "org_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = GetSecretCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "org_id", Value: 1}, {Key: "name", Value: 1}, {Key: "version", Value: -1}},
+			Options: options.Index().SetUnique(true),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = GetRepoCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "project_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = GetProjectCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "org_id", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true).SetCollation(nameCollation),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = GetProjectMemberCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "project_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "org_id", Value: 1}, {Key: "user_id", Value: 1}}},
+	})
+	return err
+}
+
+// nameCollation compares org names case-insensitively.
+var nameCollation = &options.Collation{Locale: "en", Strength: 2}
+
+const orgNameIndex = "name_ci_unique"
+
+// IsDuplicateOrgName reports whether err is a write conflicting with another
+// org's name, as opposed to its slug.
+func IsDuplicateOrgName(err error) bool {
+	return mongo.IsDuplicateKeyError(err) && strings.Contains(err.Error(), orgNameIndex)
 }
 
 func GetOrgCollection() *mongo.Collection {
 	return MongoClient.Database("casbin").Collection("organizations")
 }
+
+func GetPolicySyncCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("policy_sync")
+}
+
+func GetAuditCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("audit_log")
+}
+
+func GetMembershipCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("memberships")
+}
+
+func GetInviteCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("invites")
+}
+
+func GetTeamCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("teams")
+}
+
+func GetTeamMemberCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("team_members")
+}
+
+func GetRepoCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("repos")
+}
+
+// GetCasbinRuleCollection is where the Casbin adapter keeps its rules. It is
+// only read directly for reporting.
+func GetCasbinRuleCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("casbin_rule")
+}
+
+func GetProjectCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("projects")
+}
+
+func GetProjectMemberCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("project_members")
+}
+
+func GetDomainClaimCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("domain_claims")
+}
+
+func GetAutoJoinCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("auto_joins")
+}
+
+func GetJoinRequestCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("join_requests")
+}
+
+// GetLastSeenCollection is keyed by user and domain rather than org_id, so
+// org purges clean it up separately.
+func GetLastSeenCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("last_seen")
+}
+
+func GetOrgKeyCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("org_keys")
+}
+
+func GetSecretCollection() *mongo.Collection {
+	return MongoClient.Database("casbin").Collection("secrets")
+}
+
+// GetOrgScopedCollections returns the collections whose documents carry an
+// org_id and must be removed together with the org.
+func GetOrgScopedCollections() []*mongo.Collection {
+	return []*mongo.Collection{
+		GetMembershipCollection(),
+		GetInviteCollection(),
+		GetTeamCollection(),
+		GetTeamMemberCollection(),
+		GetDomainClaimCollection(),
+		GetRepoCollection(),
+		GetProjectCollection(),
+		GetProjectMemberCollection(),
+		GetSecretCollection(),
+		GetOrgKeyCollection(),
+		GetJoinRequestCollection(),
+		GetAutoJoinCollection(),
+	}
+}
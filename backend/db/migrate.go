@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrateEmbeddedMembers moves members still embedded in organization
+// documents into the memberships collection and strips the embedded array.
+// Orgs that were already migrated are skipped, so it is safe to run on every
+// start.
+func MigrateEmbeddedMembers(ctx context.Context) (int, error) {
+	orgs := GetOrgCollection()
+	memberships := GetMembershipCollection()
+
+	cursor, err := orgs.Find(ctx, bson.M{"users": bson.M{"$exists": true}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var org struct {
+			ID        primitive.ObjectID `bson:"_id"`
+			CreatedAt time.Time          `bson:"created_at"`
+			Users     []struct {
+				ID   string `bson:"_id"`
+				Role string `bson:"role"`
+			} `bson:"users"`
+		}
+		if err := cursor.Decode(&org); err != nil {
+			return migrated, err
+		}
+
+		orgID := org.ID.Hex()
+		for _, user := range org.Users {
+			_, err := memberships.UpdateOne(ctx,
+				bson.M{"org_id": orgID, "user_id": user.ID},
+				bson.M{"$setOnInsert": bson.M{
+					"org_id":    orgID,
+					"user_id":   user.ID,
+					"role":      user.Role,
+					"joined_at": org.CreatedAt,
+				}},
+				options.Update().SetUpsert(true),
+			)
+			if err != nil {
+				return migrated, err
+			}
+		}
+
+		if _, err := orgs.UpdateOne(ctx, bson.M{"_id": org.ID}, bson.M{"$unset": bson.M{"users": ""}}); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, cursor.Err()
+}
+
+// BackfillAutoJoins records members who were auto-joined before auto-joins
+// were tracked, so leaving those orgs sticks too. Existing records are kept,
+// so it is safe to run on every start.
+func BackfillAutoJoins(ctx context.Context) error {
+	cursor, err := GetMembershipCollection().Find(ctx, bson.M{"invited_by": bson.M{"$regex": "^domain:"}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var m struct {
+			OrgID     string    `bson:"org_id"`
+			UserID    string    `bson:"user_id"`
+			JoinedAt  time.Time `bson:"joined_at"`
+			InvitedBy string    `bson:"invited_by"`
+		}
+		if err := cursor.Decode(&m); err != nil {
+			return err
+		}
+		_, err := GetAutoJoinCollection().UpdateOne(ctx,
+			bson.M{"org_id": m.OrgID, "user_id": m.UserID},
+			bson.M{"$setOnInsert": bson.M{
+				"domain":    strings.TrimPrefix(m.InvitedBy, "domain:"),
+				"joined_at": m.JoinedAt,
+			}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxSlugLength = 60
+
+var (
+	slugInvalid = regexp.MustCompile(`[^a-z0-9]+`)
+	slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+)
+
+// Slugify turns an org name into a URL-safe slug.
+func Slugify(name string) string {
+	slug := slugInvalid.ReplaceAllString(strings.ToLower(name), "-")
+	slug = strings.Trim(slug, "-")
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+	if slug == "" || primitive.IsValidObjectID(slug) {
+		slug = "org-" + slug
+	}
+	return strings.TrimRight(slug, "-")
+}
+
+// ValidSlug reports whether slug can be used as-is. Slugs that look like an
+// ObjectID are rejected because routes accept either.
+func ValidSlug(slug string) bool {
+	return len(slug) <= maxSlugLength && slugPattern.MatchString(slug) && !primitive.IsValidObjectID(slug)
+}
+
+// UniqueOrgSlug derives a slug from name that no other org uses, appending a
+// counter when needed. exclude is the org being renamed, if any.
+func UniqueOrgSlug(ctx context.Context, name string, exclude primitive.ObjectID) (string, error) {
+	base := Slugify(name)
+	for i := 1; ; i++ {
+		slug := base
+		if i > 1 {
+			slug = fmt.Sprintf("%s-%d", base, i)
+		}
+		filter := bson.M{"slug": slug}
+		if !exclude.IsZero() {
+			filter["_id"] = bson.M{"$ne": exclude}
+		}
+		count, err := GetOrgCollection().CountDocuments(ctx, filter)
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return slug, nil
+		}
+	}
+}
+
+// backfillOrgSlugs gives every org created before slugs existed a slug, so
+// the unique index can be built.
+func backfillOrgSlugs(ctx context.Context) error {
+	cursor, err := GetOrgCollection().Find(ctx, bson.M{"slug": bson.M{"$in": bson.A{nil, ""}}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var org struct {
+			ID   primitive.ObjectID `bson:"_id"`
+			Name string             `bson:"name"`
+		}
+		if err := cursor.Decode(&org); err != nil {
+			return err
+		}
+		slug, err := UniqueOrgSlug(ctx, org.Name, org.ID)
+		if err != nil {
+			return err
+		}
+		if _, err := GetOrgCollection().UpdateOne(ctx, bson.M{"_id": org.ID}, bson.M{"$set": bson.M{"slug": slug}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// backfillOrgNames renames orgs whose names differ from an older org's only
+// by case, so the unique name index can be built. The oldest org keeps the
+// name; the others get a " (2)", " (3)", ... suffix.
+func backfillOrgNames(ctx context.Context) error {
+	cursor, err := GetOrgCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$name", "ids": bson.M{"$push": "$_id"}, "names": bson.M{"$push": "$name"}}}},
+		{{Key: "$match", Value: bson.M{"ids.1": bson.M{"$exists": true}}}},
+	}, options.Aggregate().SetCollation(nameCollation))
+	if err != nil {
+		return err
+	}
+	var groups []struct {
+		IDs   []primitive.ObjectID `bson:"ids"`
+		Names []string             `bson:"names"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		for i, id := range group.IDs[1:] {
+			name := group.Names[i+1]
+			for n := 2; ; n++ {
+				candidate := fmt.Sprintf("%s (%d)", name, n)
+				taken, err := OrgNameTaken(ctx, candidate, id)
+				if err != nil {
+					return err
+				}
+				if !taken {
+					name = candidate
+					break
+				}
+			}
+			if _, err := GetOrgCollection().UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"name": name}}); err != nil {
+				return err
+			}
+			fmt.Printf("Warning: Renamed org %s to %q to make its name unique\n", id.Hex(), name)
+		}
+	}
+	return nil
+}
+
+// ResolveOrgID maps an org route parameter, either an ObjectID hex or a
+// slug, to the org's ID hex.
+func ResolveOrgID(ctx context.Context, idOrSlug string) (string, error) {
+	if primitive.IsValidObjectID(idOrSlug) {
+		return idOrSlug, nil
+	}
+	var org struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := GetOrgCollection().FindOne(ctx, bson.M{"slug": idOrSlug}).Decode(&org); err != nil {
+		return "", err
+	}
+	return org.ID.Hex(), nil
+}
+
+// OrgNameTaken reports whether another org already uses name, ignoring case.
+func OrgNameTaken(ctx context.Context, name string, exclude primitive.ObjectID) (bool, error) {
+	filter := bson.M{"name": name}
+	if !exclude.IsZero() {
+		filter["_id"] = bson.M{"$ne": exclude}
+	}
+	count, err := GetOrgCollection().CountDocuments(ctx, filter, options.Count().SetCollation(nameCollation))
+	return count > 0, err
+}
@@ -0,0 +1,120 @@
+// Package dnsverify proves control of an email domain through a DNS TXT
+// record. The resolver is pluggable so the check can be stubbed locally.
+package dnsverify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const recordPrefix = "orgauth-verification="
+
+// Resolver looks up the TXT records published under name.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// StubResolver answers from a JSON file mapping record names to their TXT
+// values. The file is read on every lookup so it can be edited while the
+// server runs.
+type StubResolver struct {
+	Path string
+}
+
+func (s StubResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stub records: %w", err)
+	}
+	var records map[string][]string
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("invalid stub records: %w", err)
+	}
+	return records[name], nil
+}
+
+var (
+	mu       sync.RWMutex
+	resolver Resolver = defaultResolver()
+)
+
+// defaultResolver uses the system resolver unless DNS_STUB_FILE points at a
+// stub records file.
+func defaultResolver() Resolver {
+	if path := os.Getenv("DNS_STUB_FILE"); path != "" {
+		return StubResolver{Path: path}
+	}
+	return net.DefaultResolver
+}
+
+func SetResolver(r Resolver) {
+	mu.Lock()
+	defer mu.Unlock()
+	resolver = r
+}
+
+func current() Resolver {
+	mu.RLock()
+	defer mu.RUnlock()
+	return resolver
+}
+
+// NewToken returns a random verification token.
+func NewToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// RecordName is where the TXT record for domain must be published.
+func RecordName(domain string) string {
+	return "_orgauth-verification." + domain
+}
+
+// RecordValue is the TXT value that proves token.
+func RecordValue(token string) string {
+	return recordPrefix + token
+}
+
+// Verify reports whether domain publishes the TXT record for token.
+func Verify(ctx context.Context, domain, token string) (bool, error) {
+	records, err := current().LookupTXT(ctx, RecordName(domain))
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return slices.Contains(records, RecordValue(token)), nil
+}
+
+// NormalizeDomain lowercases domain and strips a leading "@", returning ""
+// when it is not a plausible domain name.
+func NormalizeDomain(domain string) string {
+	domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "@")
+	if !strings.Contains(domain, ".") || strings.ContainsAny(domain, " /@") || len(domain) > 253 {
+		return ""
+	}
+	return domain
+}
+
+// EmailDomain returns the normalized domain part of an email address.
+func EmailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return NormalizeDomain(email[at+1:])
+}
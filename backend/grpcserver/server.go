@@ -0,0 +1,145 @@
+package grpcserver
+
+import (
+	authzv1 "backend/proto/authz/v1"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/casbin/casbin/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+type AuthorizationServer struct {
+	authzv1.UnimplementedAuthorizationServiceServer
+	Enforcer *casbin.Enforcer
+}
+
+func domainOrMain(dom string) string {
+	if dom == "" {
+		return "main"
+	}
+	return dom
+}
+
+func (s *AuthorizationServer) check(req *authzv1.CheckRequest) (*authzv1.CheckResponse, error) {
+	if req.GetSubject() == "" || req.GetObject() == "" || req.GetAction() == "" {
+		return nil, status.Error(codes.InvalidArgument, "subject, object and action are required")
+	}
+	ok, err := s.Enforcer.Enforce(req.GetSubject(), domainOrMain(req.GetDomain()), req.GetObject(), req.GetAction())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "enforce failed: %v", err)
+	}
+	return &authzv1.CheckResponse{Allowed: ok}, nil
+}
+
+func (s *AuthorizationServer) Check(ctx context.Context, req *authzv1.CheckRequest) (*authzv1.CheckResponse, error) {
+	_ = s.Enforcer.LoadPolicy()
+	return s.check(req)
+}
+
+func (s *AuthorizationServer) BatchCheck(ctx context.Context, req *authzv1.BatchCheckRequest) (*authzv1.BatchCheckResponse, error) {
+	_ = s.Enforcer.LoadPolicy()
+	res := &authzv1.BatchCheckResponse{Results: make([]*authzv1.CheckResponse, 0, len(req.GetChecks()))}
+	for _, check := range req.GetChecks() {
+		result, err := s.check(check)
+		if err != nil {
+			return nil, err
+		}
+		res.Results = append(res.Results, result)
+	}
+	return res, nil
+}
+
+func (s *AuthorizationServer) ListRoles(ctx context.Context, req *authzv1.ListRolesRequest) (*authzv1.ListRolesResponse, error) {
+	if req.GetSubject() == "" {
+		return nil, status.Error(codes.InvalidArgument, "subject is required")
+	}
+	_ = s.Enforcer.LoadPolicy()
+	dom := domainOrMain(req.GetDomain())
+	if !req.GetImplicit() {
+		return &authzv1.ListRolesResponse{Roles: s.Enforcer.GetRolesForUserInDomain(req.GetSubject(), dom)}, nil
+	}
+	roles, err := s.Enforcer.GetImplicitRolesForUser(req.GetSubject(), dom)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to resolve roles: %v", err)
+	}
+	return &authzv1.ListRolesResponse{Roles: roles}, nil
+}
+
+func (s *AuthorizationServer) ListUsersWithRole(ctx context.Context, req *authzv1.ListUsersWithRoleRequest) (*authzv1.ListUsersWithRoleResponse, error) {
+	if req.GetRole() == "" {
+		return nil, status.Error(codes.InvalidArgument, "role is required")
+	}
+	_ = s.Enforcer.LoadPolicy()
+	users := s.Enforcer.GetUsersForRoleInDomain(req.GetRole(), domainOrMain(req.GetDomain()))
+	return &authzv1.ListUsersWithRoleResponse{Users: users}, nil
+}
+
+// tokenInterceptor rejects calls that do not carry the shared token from
+// GRPC_AUTH_TOKEN in their "authorization" metadata. Health and reflection
+// stay open so probes keep working.
+func tokenInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if info.FullMethod == healthpb.Health_Check_FullMethodName {
+			return handler(ctx, req)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+		if len(values) == 0 || subtle.ConstantTimeCompare([]byte(values[0]), []byte("Bearer "+token)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "missing or invalid token")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// loopbackAddr reports whether addr only accepts local connections.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Serve runs the gRPC API on addr. Without GRPC_AUTH_TOKEN it only agrees to
+// listen on a loopback address.
+func Serve(addr string, e *casbin.Enforcer) error {
+	token := os.Getenv("GRPC_AUTH_TOKEN")
+	if token == "" && !loopbackAddr(addr) {
+		return fmt.Errorf("GRPC_AUTH_TOKEN must be set to serve gRPC on %s", addr)
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	var opts []grpc.ServerOption
+	if token != "" {
+		opts = append(opts, grpc.UnaryInterceptor(tokenInterceptor(token)))
+	}
+	server := grpc.NewServer(opts...)
+
+	authzv1.RegisterAuthorizationServiceServer(server, &AuthorizationServer{Enforcer: e})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(authzv1.AuthorizationService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+
+	return server.Serve(lis)
+}
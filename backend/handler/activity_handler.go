@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"backend/db"
+	"backend/models"
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultInactiveDays = 30
+
+// lastSeenIn returns when each of userIDs was last active in dom. Users with
+// no recorded activity are left out.
+func lastSeenIn(ctx context.Context, dom string, userIDs []string) (map[string]time.Time, error) {
+	cursor, err := db.GetLastSeenCollection().Find(ctx, bson.M{"domain": dom, "user_id": bson.M{"$in": userIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []models.LastSeen
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		seen[row.UserID] = row.SeenAt
+	}
+	return seen, nil
+}
+
+// withLastSeen fills in the members' last activity in orgID.
+func withLastSeen(ctx context.Context, orgID string, members []models.Member) ([]models.Member, error) {
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.ID)
+	}
+	seen, err := lastSeenIn(ctx, orgID, ids)
+	if err != nil {
+		return members, err
+	}
+	for i := range members {
+		if t, ok := seen[members[i].ID]; ok {
+			members[i].LastSeenAt = &t
+		}
+	}
+	return members, nil
+}
+
+// lastOrgActivity returns the most recent activity of anyone in orgID.
+func lastOrgActivity(ctx context.Context, orgID string) (*time.Time, error) {
+	var row models.LastSeen
+	err := db.GetLastSeenCollection().FindOne(ctx, bson.M{"domain": orgID},
+		options.FindOne().SetSort(bson.D{{Key: "seen_at", Value: -1}})).Decode(&row)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &row.SeenAt, nil
+}
+
+// GetInactiveMembersHandler lists members with no activity in the org for
+// more than ?days= days (30 by default), least recently seen first. Members
+// never seen count from when they joined.
+func GetInactiveMembersHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	days := defaultInactiveDays
+	if raw := c.Query("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive number"})
+			return
+		}
+		days = n
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	members, err := orgMembers(ctx, orgID, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve members"})
+		return
+	}
+	if members, err = withLastSeen(ctx, orgID, members); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve activity"})
+		return
+	}
+
+	lastActive := func(m models.Member) time.Time {
+		if m.LastSeenAt != nil {
+			return *m.LastSeenAt
+		}
+		return m.JoinedAt
+	}
+	inactive := []models.Member{}
+	for _, m := range members {
+		if lastActive(m).Before(cutoff) {
+			inactive = append(inactive, m)
+		}
+	}
+	sort.Slice(inactive, func(i, j int) bool {
+		return lastActive(inactive[i]).Before(lastActive(inactive[j]))
+	})
+
+	c.JSON(http.StatusOK, gin.H{"data": inactive, "days": days, "cutoff": cutoff})
+}
@@ -1,28 +1,33 @@
 package handler
 
 import (
+	"backend/db"
+	"backend/models"
+	"backend/policy"
+	"backend/utils"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
 
 	"github.com/casbin/casbin/v2"
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 func GetIdentities(enforcer *casbin.Enforcer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		dom := "main"
-		resp, err := http.Get("http://localhost:4434/admin/identities")
+		data, err := utils.ListIdentities[map[string]any]()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to connect to Kratos"})
-			return
-		}
-		defer resp.Body.Close()
-
-		var data []map[string]any
-		if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
 			fmt.Println(err)
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode session"})
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to load identities"})
 			return
 		}
 
@@ -47,8 +52,26 @@ func GetIdentities(enforcer *casbin.Enforcer) gin.HandlerFunc {
 			}
 		}
 
-		c.JSON(resp.StatusCode, gin.H{"data": data})
+		c.JSON(http.StatusOK, gin.H{"data": data})
+	}
+}
+
+// mainDomainRole reports whether role is defined for the main domain by the
+// policy files, either through its own rules or in the role hierarchy.
+func mainDomainRole(enforcer *casbin.Enforcer, role string) bool {
+	rules, _ := enforcer.GetFilteredPolicy(1, "main")
+	for _, rule := range rules {
+		if rule[0] == role {
+			return true
+		}
 	}
+	links, _ := enforcer.GetFilteredGroupingPolicy(2, "main")
+	for _, link := range links {
+		if link[1] == role {
+			return true
+		}
+	}
+	return false
 }
 
 func UpdateUserRole(enforcer *casbin.Enforcer) gin.HandlerFunc {
@@ -62,6 +85,24 @@ func UpdateUserRole(enforcer *casbin.Enforcer) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 			return
 		}
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+
+		if !mainDomainRole(enforcer, req.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role"})
+			return
+		}
+		if err := policy.CheckSelfElevation(enforcer, user.(models.Identity).ID, req.UserID, req.Role, dom); err != nil {
+			abortWithViolation(c, err)
+			return
+		}
+		if err := policy.CheckSeparation(enforcer, req.UserID, req.Role, dom, true); err != nil {
+			abortWithViolation(c, err)
+			return
+		}
 
 		oldRoles := enforcer.GetRolesForUserInDomain(req.UserID, dom)
 		for _, role := range oldRoles {
@@ -77,3 +118,191 @@ func UpdateUserRole(enforcer *casbin.Enforcer) gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"message": "Role updated"})
 	}
 }
+
+// abortWithViolation reports a separation-of-duties violation, or falls back
+// to a generic error for anything else.
+func abortWithViolation(c *gin.Context, err error) {
+	var violation *policy.Violation
+	if errors.As(err, &violation) {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error":      violation.Error(),
+			"constraint": violation.Constraint,
+			"role":       violation.Role,
+			"conflicts":  violation.Conflicts,
+		})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+}
+
+type accessMatrixEntry struct {
+	UserID        string   `json:"user_id"`
+	Email         string   `json:"email"`
+	Name          string   `json:"name"`
+	Domain        string   `json:"domain"`
+	OrgName       string   `json:"org_name"`
+	Roles         []string `json:"roles"`
+	EffectiveRole string   `json:"effective_role"`
+}
+
+// roleRank orders the built-in roles from strongest to weakest. Custom roles
+// rank below all of them.
+var roleRank = []string{"admin", "writer", "reader", "invite"}
+
+// effectiveRole picks the strongest of roles. Team and project links are not
+// roles of their own and are skipped; pass implicit roles to count the role a
+// team grants.
+func effectiveRole(roles []string) string {
+	best, bestRank := "", len(roleRank)+1
+	for _, role := range roles {
+		if isTeamSubject(role) || isProjectRole(role) {
+			continue
+		}
+		rank := len(roleRank)
+		for i, known := range roleRank {
+			if role == known {
+				rank = i
+				break
+			}
+		}
+		if rank < bestRank {
+			best, bestRank = role, rank
+		}
+	}
+	if best == "" {
+		return "none"
+	}
+	return best
+}
+
+func fetchIdentities() ([]models.Identity, error) {
+	return utils.ListIdentities[models.Identity]()
+}
+
+func fetchIdentity(id string) (models.Identity, error) {
+	var identity models.Identity
+	resp, err := http.Get("http://localhost:4434/admin/identities/" + url.PathEscape(id))
+	if err != nil {
+		return identity, fmt.Errorf("failed to connect to Kratos: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return identity, fmt.Errorf("kratos returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&identity); err != nil {
+		return identity, fmt.Errorf("failed to decode identity: %w", err)
+	}
+	return identity, nil
+}
+
+func GetAccessMatrix(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgFilter := c.Query("org")
+		roleFilter := c.Query("role")
+		userFilter := strings.ToLower(c.Query("user"))
+
+		identities, err := fetchIdentities()
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+
+		cursor, err := db.GetOrgCollection().Find(context.TODO(), bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizations"})
+			return
+		}
+		defer cursor.Close(context.TODO())
+
+		var orgs []models.Organization
+		if err := cursor.All(context.TODO(), &orgs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode organizations"})
+			return
+		}
+		orgNames := map[string]string{"main": "main"}
+		for _, org := range orgs {
+			orgNames[org.ID.Hex()] = org.Name
+		}
+
+		_ = enforcer.LoadPolicy()
+		grouping, err := enforcer.GetGroupingPolicy()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read grouping rules"})
+			return
+		}
+		userDomains := map[string]map[string]bool{}
+		for _, rule := range grouping {
+			if len(rule) < 3 {
+				continue
+			}
+			if userDomains[rule[0]] == nil {
+				userDomains[rule[0]] = map[string]bool{}
+			}
+			userDomains[rule[0]][rule[2]] = true
+		}
+
+		entries := []accessMatrixEntry{}
+		for _, identity := range identities {
+			if userFilter != "" &&
+				identity.ID != userFilter &&
+				!strings.Contains(strings.ToLower(identity.Traits.Email), userFilter) {
+				continue
+			}
+
+			domains := []string{"main"}
+			for dom := range userDomains[identity.ID] {
+				if dom != "main" {
+					domains = append(domains, dom)
+				}
+			}
+			sort.Strings(domains[1:])
+
+			for _, dom := range domains {
+				if orgFilter != "" && dom != orgFilter && orgNames[dom] != orgFilter {
+					continue
+				}
+				roles := enforcer.GetRolesForUserInDomain(identity.ID, dom)
+				implicit, err := enforcer.GetImplicitRolesForUser(identity.ID, dom)
+				if err != nil {
+					implicit = roles
+				}
+				entry := accessMatrixEntry{
+					UserID:        identity.ID,
+					Email:         identity.Traits.Email,
+					Name:          identity.Traits.Name,
+					Domain:        dom,
+					OrgName:       orgNames[dom],
+					Roles:         roles,
+					EffectiveRole: effectiveRole(implicit),
+				}
+				if roleFilter != "" && entry.EffectiveRole != roleFilter && !slices.Contains(roles, roleFilter) {
+					continue
+				}
+				entries = append(entries, entry)
+			}
+		}
+
+		if c.Query("format") != "csv" {
+			c.JSON(http.StatusOK, gin.H{"data": entries})
+			return
+		}
+
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", "attachment; filename=access-matrix.csv")
+		w := csv.NewWriter(c.Writer)
+		_ = w.Write([]string{"user_id", "email", "name", "domain", "org_name", "roles", "effective_role"})
+		for _, entry := range entries {
+			_ = w.Write([]string{
+				entry.UserID,
+				entry.Email,
+				entry.Name,
+				entry.Domain,
+				entry.OrgName,
+				strings.Join(entry.Roles, ";"),
+				entry.EffectiveRole,
+			})
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,278 @@
+package handler
+
+import (
+	"backend/db"
+	"backend/models"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	analyticsDayFormat   = "2006-01-02"
+	defaultAnalyticsDays = 30
+	maxAnalyticsDays     = 366
+)
+
+type dailyCount struct {
+	Date  string `json:"date" bson:"_id"`
+	Count int64  `json:"count" bson:"count"`
+}
+
+type inviteStats struct {
+	Total          int64   `json:"total"`
+	Accepted       int64   `json:"accepted"`
+	Pending        int64   `json:"pending"`
+	AcceptanceRate float64 `json:"acceptance_rate"`
+}
+
+type analyticsReport struct {
+	From             string           `json:"from"`
+	To               string           `json:"to"`
+	SignupsPerDay    []dailyCount     `json:"signups_per_day"`
+	OrgsPerDay       []dailyCount     `json:"orgs_per_day"`
+	Invites          inviteStats      `json:"invites"`
+	ActiveOrgs       int64            `json:"active_orgs"`
+	AverageOrgSize   float64          `json:"average_org_size"`
+	RoleDistribution map[string]int64 `json:"role_distribution"`
+}
+
+// parseAnalyticsRange reads from and to (YYYY-MM-DD, both inclusive). The
+// default is the last 30 days.
+func parseAnalyticsRange(c *gin.Context) (time.Time, time.Time, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to := today
+	if raw := c.Query("to"); raw != "" {
+		t, err := time.Parse(analyticsDayFormat, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("to must be a date like 2024-01-31")
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -(defaultAnalyticsDays - 1))
+	if raw := c.Query("from"); raw != "" {
+		t, err := time.Parse(analyticsDayFormat, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("from must be a date like 2024-01-01")
+		}
+		from = t
+	}
+	if from.After(to) {
+		return time.Time{}, time.Time{}, errors.New("from must not be after to")
+	}
+	if to.Sub(from) >= maxAnalyticsDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("range can cover at most %d days", maxAnalyticsDays)
+	}
+	return from, to, nil
+}
+
+// fillDays returns one entry per day from..to, taking counts from counts.
+func fillDays(from, to time.Time, counts map[string]int64) []dailyCount {
+	days := []dailyCount{}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		key := day.Format(analyticsDayFormat)
+		days = append(days, dailyCount{Date: key, Count: counts[key]})
+	}
+	return days
+}
+
+func signupsPerDay(from, end time.Time) (map[string]int64, error) {
+	identities, err := fetchIdentities()
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int64{}
+	for _, identity := range identities {
+		created := identity.CreatedAt.UTC()
+		if created.Before(from) || !created.Before(end) {
+			continue
+		}
+		counts[created.Format(analyticsDayFormat)]++
+	}
+	return counts, nil
+}
+
+func orgsPerDay(ctx context.Context, from, end time.Time) (map[string]int64, error) {
+	cursor, err := db.GetOrgCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"created_at": bson.M{"$gte": from, "$lt": end}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$created_at"}},
+			"count": bson.M{"$sum": 1},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []dailyCount
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Date] = row.Count
+	}
+	return counts, nil
+}
+
+// invitesInRange counts the invites sent in the range by their status.
+func invitesInRange(ctx context.Context, from, end time.Time) (inviteStats, error) {
+	var stats inviteStats
+	cursor, err := db.GetInviteCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"created_at": bson.M{"$gte": from, "$lt": end}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return stats, err
+	}
+	var rows []struct {
+		Status string `bson:"_id"`
+		Count  int64  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return stats, err
+	}
+	for _, row := range rows {
+		stats.Total += row.Count
+		switch row.Status {
+		case "accepted":
+			stats.Accepted = row.Count
+		case "pending":
+			stats.Pending = row.Count
+		}
+	}
+	if stats.Total > 0 {
+		stats.AcceptanceRate = float64(stats.Accepted) / float64(stats.Total)
+	}
+	return stats, nil
+}
+
+// roleDistribution counts current memberships of active orgs by role. It
+// also returns how many active orgs there are.
+func roleDistribution(ctx context.Context) (map[string]int64, int64, int64, error) {
+	cursor, err := db.GetOrgCollection().Find(ctx, bson.M{"archived_at": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	var orgs []models.Organization
+	if err := cursor.All(ctx, &orgs); err != nil {
+		return nil, 0, 0, err
+	}
+	orgIDs := make([]string, 0, len(orgs))
+	for _, org := range orgs {
+		orgIDs = append(orgIDs, org.ID.Hex())
+	}
+
+	cursor, err = db.GetMembershipCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"org_id": bson.M{"$in": orgIDs}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$role", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	var rows []struct {
+		Role  string `bson:"_id"`
+		Count int64  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, 0, 0, err
+	}
+	roles := map[string]int64{}
+	var members int64
+	for _, row := range rows {
+		roles[row.Role] = row.Count
+		members += row.Count
+	}
+	return roles, members, int64(len(orgIDs)), nil
+}
+
+// GetAnalyticsHandler reports growth and health metrics for global admins.
+// from and to (YYYY-MM-DD) bound the daily series and the invite stats; org
+// size and role distribution describe the current state. format=csv returns
+// the same data as metric,key,value rows.
+func GetAnalyticsHandler(c *gin.Context) {
+	from, to, err := parseAnalyticsRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	end := to.AddDate(0, 0, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	signups, err := signupsPerDay(from, end)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	orgs, err := orgsPerDay(ctx, from, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate organizations"})
+		return
+	}
+	invites, err := invitesInRange(ctx, from, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate invites"})
+		return
+	}
+	roles, members, activeOrgs, err := roleDistribution(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate memberships"})
+		return
+	}
+
+	report := analyticsReport{
+		From:             from.Format(analyticsDayFormat),
+		To:               to.Format(analyticsDayFormat),
+		SignupsPerDay:    fillDays(from, to, signups),
+		OrgsPerDay:       fillDays(from, to, orgs),
+		Invites:          invites,
+		ActiveOrgs:       activeOrgs,
+		RoleDistribution: roles,
+	}
+	if activeOrgs > 0 {
+		report.AverageOrgSize = float64(members) / float64(activeOrgs)
+	}
+
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", "attachment; filename=analytics-"+report.From+"-"+report.To+".csv")
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"metric", "key", "value"})
+	for _, day := range report.SignupsPerDay {
+		_ = w.Write([]string{"signups", day.Date, strconv.FormatInt(day.Count, 10)})
+	}
+	for _, day := range report.OrgsPerDay {
+		_ = w.Write([]string{"orgs_created", day.Date, strconv.FormatInt(day.Count, 10)})
+	}
+	_ = w.Write([]string{"invites", "total", strconv.FormatInt(invites.Total, 10)})
+	_ = w.Write([]string{"invites", "accepted", strconv.FormatInt(invites.Accepted, 10)})
+	_ = w.Write([]string{"invites", "pending", strconv.FormatInt(invites.Pending, 10)})
+	_ = w.Write([]string{"invites", "acceptance_rate", strconv.FormatFloat(invites.AcceptanceRate, 'f', 4, 64)})
+	_ = w.Write([]string{"orgs", "active", strconv.FormatInt(activeOrgs, 10)})
+	_ = w.Write([]string{"orgs", "average_size", strconv.FormatFloat(report.AverageOrgSize, 'f', 2, 64)})
+	roleNames := make([]string, 0, len(roles))
+	for role := range roles {
+		roleNames = append(roleNames, role)
+	}
+	sort.Strings(roleNames)
+	for _, role := range roleNames {
+		_ = w.Write([]string{"roles", role, strconv.FormatInt(roles[role], 10)})
+	}
+	w.Flush()
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"backend/models"
+	"backend/policy"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -44,6 +46,12 @@ func RegisterHandler(e *casbin.Enforcer) gin.HandlerFunc {
 					fmt.Println("Role assignment error:", err)
 				}
 			}
+			var registered struct {
+				Identity models.Identity `json:"identity"`
+			}
+			if err := json.Unmarshal(kratosRespBody, &registered); err == nil && registered.Identity.ID != "" {
+				autoJoinVerifiedDomains(e, registered.Identity)
+			}
 		}
 
 		c.Data(statusCode, "application/json", kratosRespBody)
@@ -109,7 +117,7 @@ func assignDefaultRole(e *casbin.Enforcer, userID string) error {
 		return nil
 	}
 
-	_, err = e.AddGroupingPolicy(userID, "reader", dom)
+	_, err = policy.AddGroupingPolicy(e, userID, "reader", dom)
 	if err != nil {
 		return fmt.Errorf("failed to assign role: %w", err)
 	}
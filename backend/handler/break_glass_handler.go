@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"backend/models"
+	"backend/temporal/workflows"
+	"backend/utils"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+func breakGlassTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("BREAK_GLASS_TTL"))
+	if err != nil || ttl <= 0 {
+		return time.Hour
+	}
+	return ttl
+}
+
+func BreakGlassHandler(temporalClient client.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Credential string `json:"credential" binding:"required"`
+			Reason     string `json:"reason" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Credential and reason are required"})
+			return
+		}
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+
+		expected := strings.ToLower(os.Getenv("BREAK_GLASS_CREDENTIAL_SHA256"))
+		if expected == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Break-glass access is not configured"})
+			return
+		}
+
+		input := models.BreakGlassInput{
+			UserId:   user.(models.Identity).ID,
+			Email:    user.(models.Identity).Traits.Email,
+			Reason:   req.Reason,
+			Duration: breakGlassTTL(),
+		}
+
+		sum := sha256.Sum256([]byte(req.Credential))
+		if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(expected)) != 1 {
+			if err := utils.BreakGlassAlert(context.TODO(), "denied", input); err != nil {
+				fmt.Println("Warning: failed to record break-glass attempt:", err)
+			}
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid emergency credential"})
+			return
+		}
+
+		we, err := temporalClient.ExecuteWorkflow(
+			context.Background(),
+			client.StartWorkflowOptions{
+				ID:                                       "break-glass-" + input.UserId,
+				TaskQueue:                                "BREAK_GLASS_QUEUE",
+				WorkflowExecutionErrorWhenAlreadyStarted: true,
+			},
+			workflows.BreakGlassWorkflow,
+			input,
+		)
+		var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+		if errors.As(err, &alreadyStarted) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Break-glass access is already active for this user"})
+			return
+		}
+		if err != nil {
+			fmt.Printf("Warning: Failed to start break-glass workflow: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request emergency access"})
+			return
+		}
+
+		// The grant is made by the workflow and can still be rejected there,
+		// so this only confirms the request.
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":     "Emergency admin access requested",
+			"workflow_id": we.GetID(),
+			"expires_at":  time.Now().Add(input.Duration),
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"backend/db"
+	"backend/models"
+	"backend/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	dashboardOrgLimit          = 50
+	dashboardInviteLimit       = 20
+	dashboardNotificationLimit = 10
+	dashboardRepoLimit         = 10
+)
+
+type dashboardOrg struct {
+	models.Organization
+	Role string `json:"role"`
+}
+
+type dashboardInvite struct {
+	models.Invite
+	OrgName string `json:"org_name"`
+}
+
+// dashboardSection is one independently loaded part of the dashboard.
+type dashboardSection struct {
+	name    string
+	timeout time.Duration
+	load    func(ctx context.Context) (interface{}, error)
+}
+
+// run loads the section, giving up once its timeout passes even if load
+// itself doesn't watch ctx.
+func (s dashboardSection) run(parent context.Context) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(parent, s.timeout)
+	defer cancel()
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := s.load(ctx)
+		done <- result{value, err}
+	}()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timed out after %s", s.timeout)
+	}
+}
+
+func dashboardOrgs(ctx context.Context, enforcer *casbin.Enforcer, userID string) ([]dashboardOrg, error) {
+	memberships, err := findMemberships(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	roles := make(map[string]string, len(memberships))
+	ids := make([]primitive.ObjectID, 0, len(memberships))
+	for _, m := range memberships {
+		if id, err := primitive.ObjectIDFromHex(m.OrgID); err == nil {
+			ids = append(ids, id)
+			roles[m.OrgID] = m.Role
+		}
+	}
+
+	cursor, err := db.GetOrgCollection().Find(ctx,
+		bson.M{"_id": bson.M{"$in": ids}, "archived_at": bson.M{"$exists": false}},
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}}).SetLimit(dashboardOrgLimit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var orgs []models.Organization
+	if err := cursor.All(ctx, &orgs); err != nil {
+		return nil, err
+	}
+
+	result := make([]dashboardOrg, 0, len(orgs))
+	for _, org := range orgs {
+		orgID := org.ID.Hex()
+		// Roles granted through teams count too.
+		implicit, _ := enforcer.GetImplicitRolesForUser(userID, orgID)
+		role := effectiveRole(implicit)
+		if role == "none" {
+			role = roles[orgID]
+		}
+		org.Visibility = orgVisibility(org)
+		result = append(result, dashboardOrg{Organization: org, Role: role})
+	}
+	return result, nil
+}
+
+func dashboardInvites(ctx context.Context, userID string) ([]dashboardInvite, error) {
+	cursor, err := db.GetInviteCollection().Find(ctx,
+		bson.M{"user_id": userID, "status": "pending"},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(dashboardInviteLimit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var invites []models.Invite
+	if err := cursor.All(ctx, &invites); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(invites))
+	for _, invite := range invites {
+		if id, err := primitive.ObjectIDFromHex(invite.OrgID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	cursor, err = db.GetOrgCollection().Find(ctx, bson.M{"_id": bson.M{"$in": ids}, "archived_at": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"name": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var orgs []models.Organization
+	if err := cursor.All(ctx, &orgs); err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(orgs))
+	for _, org := range orgs {
+		names[org.ID.Hex()] = org.Name
+	}
+
+	result := make([]dashboardInvite, 0, len(invites))
+	for _, invite := range invites {
+		// Invites to archived orgs can't be accepted, so they're left out.
+		if name, ok := names[invite.OrgID]; ok {
+			result = append(result, dashboardInvite{Invite: invite, OrgName: name})
+		}
+	}
+	return result, nil
+}
+
+// dashboardRepos lists the user's most recently created GitHub repos.
+func dashboardRepos(ctx context.Context, token string) ([]map[string]interface{}, error) {
+	if token == "" {
+		return nil, errors.New("GitHub account not connected")
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET",
+		fmt.Sprintf("https://api.github.com/user/repos?sort=created&direction=desc&per_page=%d", dashboardRepoLimit), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "token "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub returned %s", resp.Status)
+	}
+	var repos []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&repos); err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+// DashboardHandler gathers everything the dashboard shows in one request.
+// Sections load concurrently, each with its own timeout; a section that
+// fails or times out is null in the response and its error is listed under
+// "errors", while the others are still returned.
+func DashboardHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		identity := user.(models.Identity)
+		token, _ := c.Cookie("github_token")
+
+		sections := []dashboardSection{
+			{name: "orgs", timeout: 3 * time.Second, load: func(ctx context.Context) (interface{}, error) {
+				return dashboardOrgs(ctx, enforcer, identity.ID)
+			}},
+			{name: "invites", timeout: 2 * time.Second, load: func(ctx context.Context) (interface{}, error) {
+				return dashboardInvites(ctx, identity.ID)
+			}},
+			{name: "notifications", timeout: 3 * time.Second, load: func(ctx context.Context) (interface{}, error) {
+				return utils.FetchNotifications(ctx, identity.Traits.Email, dashboardNotificationLimit)
+			}},
+			{name: "repos", timeout: 4 * time.Second, load: func(ctx context.Context) (interface{}, error) {
+				return dashboardRepos(ctx, token)
+			}},
+		}
+
+		var (
+			mu       sync.Mutex
+			wg       sync.WaitGroup
+			response = gin.H{}
+			failures = map[string]string{}
+		)
+		for _, section := range sections {
+			wg.Add(1)
+			go func(s dashboardSection) {
+				defer wg.Done()
+				value, err := s.run(c.Request.Context())
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					fmt.Printf("Warning: dashboard section %s failed: %v\n", s.name, err)
+					response[s.name] = nil
+					failures[s.name] = err.Error()
+					return
+				}
+				response[s.name] = value
+			}(section)
+		}
+		wg.Wait()
+
+		role, _ := c.Get("role")
+		response["user"] = identity
+		response["role"] = role
+		response["errors"] = failures
+		c.JSON(http.StatusOK, response)
+	}
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"backend/db"
+	"backend/models"
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Org visibility: private orgs are only seen by their members, internal orgs
+// by any signed-in user, public orgs by everyone.
+const (
+	visibilityPrivate  = "private"
+	visibilityInternal = "internal"
+	visibilityPublic   = "public"
+)
+
+var orgVisibilities = []string{visibilityPrivate, visibilityInternal, visibilityPublic}
+
+func orgVisibility(org models.Organization) string {
+	if org.Visibility == "" {
+		return visibilityPrivate
+	}
+	return org.Visibility
+}
+
+// visibleTo lists the visibilities a non-member can see.
+func visibleTo(authenticated bool) []string {
+	if authenticated {
+		return []string{visibilityInternal, visibilityPublic}
+	}
+	return []string{visibilityPublic}
+}
+
+// orgDiscoverable reports whether a non-member may see org.
+func orgDiscoverable(org models.Organization, authenticated bool) bool {
+	return org.ArchivedAt == nil && slices.Contains(visibleTo(authenticated), orgVisibility(org))
+}
+
+// memberCounts counts the members of each of orgIDs.
+func memberCounts(ctx context.Context, orgIDs []string) (map[string]int64, error) {
+	cursor, err := db.GetMembershipCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"org_id": bson.M{"$in": orgIDs}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$org_id", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		OrgID string `bson:"_id"`
+		Count int64  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.OrgID] = row.Count
+	}
+	return counts, nil
+}
+
+func directoryOrg(org models.Organization, memberCount int64) models.DirectoryOrg {
+	return models.DirectoryOrg{
+		ID:          org.ID,
+		Name:        org.Name,
+		Slug:        org.Slug,
+		Description: org.Description,
+		Visibility:  orgVisibility(org),
+		MemberCount: memberCount,
+		CreatedAt:   org.CreatedAt,
+	}
+}
+
+// GetOrgDirectoryHandler lists the orgs the caller can see: public orgs for
+// everyone, internal orgs for signed-in users, and the caller's own orgs. It
+// takes the same paging and search parameters as the other org listings.
+func GetOrgDirectoryHandler(c *gin.Context) {
+	user, authenticated := c.Get("user")
+
+	visible := bson.A{bson.M{"visibility": bson.M{"$in": visibleTo(authenticated)}}}
+	if authenticated {
+		memberships, err := findMemberships(context.TODO(), bson.M{"user_id": user.(models.Identity).ID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizations"})
+			return
+		}
+		ids := make([]primitive.ObjectID, 0, len(memberships))
+		for _, m := range memberships {
+			if id, err := primitive.ObjectIDFromHex(m.OrgID); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		visible = append(visible, bson.M{"_id": bson.M{"$in": ids}})
+	}
+
+	orgs, nextCursor, ok := findOrgPage(c, bson.M{
+		"archived_at": bson.M{"$exists": false},
+		"$or":         visible,
+	})
+	if !ok {
+		return
+	}
+
+	orgIDs := make([]string, 0, len(orgs))
+	for _, org := range orgs {
+		orgIDs = append(orgIDs, org.ID.Hex())
+	}
+	counts, err := memberCounts(context.TODO(), orgIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count members"})
+		return
+	}
+	entries := make([]models.DirectoryOrg, 0, len(orgs))
+	for _, org := range orgs {
+		entries = append(entries, directoryOrg(org, counts[org.ID.Hex()]))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": entries, "next_cursor": nextCursor})
+}
@@ -0,0 +1,448 @@
+package handler
+
+import (
+	"backend/db"
+	"backend/dnsverify"
+	"backend/models"
+	"backend/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Admin is never handed out by domain; it has to be granted explicitly.
+var domainJoinRoles = []string{"writer", "reader"}
+
+type domainClaimView struct {
+	models.DomainClaim
+	RecordName  string `json:"record_name"`
+	RecordValue string `json:"record_value"`
+}
+
+func newDomainClaimView(claim models.DomainClaim) domainClaimView {
+	return domainClaimView{
+		DomainClaim: claim,
+		RecordName:  dnsverify.RecordName(claim.Domain),
+		RecordValue: dnsverify.RecordValue(claim.Token),
+	}
+}
+
+// verifiedEmailDomains returns the domains of the identity's verified email
+// addresses.
+func verifiedEmailDomains(identity models.Identity) []string {
+	var domains []string
+	for _, address := range identity.VerifiableAddresses {
+		if !address.Verified || address.Via != "email" {
+			continue
+		}
+		if domain := dnsverify.EmailDomain(address.Value); domain != "" && !slices.Contains(domains, domain) {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// joinableClaims lists the verified claims matching the identity's verified
+// email domains for orgs the user is not yet a member of.
+func joinableClaims(ctx context.Context, identity models.Identity, filter bson.M) ([]models.DomainClaim, error) {
+	domains := verifiedEmailDomains(identity)
+	if len(domains) == 0 {
+		return nil, nil
+	}
+	filter["domain"] = bson.M{"$in": domains}
+	filter["verified"] = true
+
+	cursor, err := db.GetDomainClaimCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var claims []models.DomainClaim
+	if err := cursor.All(ctx, &claims); err != nil {
+		return nil, err
+	}
+
+	joinable := claims[:0]
+	for _, claim := range claims {
+		if _, err := getMembership(ctx, claim.OrgID, identity.ID); err != mongo.ErrNoDocuments {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		removed, err := db.GetAutoJoinCollection().CountDocuments(ctx, bson.M{
+			"org_id": claim.OrgID, "user_id": identity.ID, "removed_at": bson.M{"$exists": true},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if removed == 0 {
+			joinable = append(joinable, claim)
+		}
+	}
+	return joinable, nil
+}
+
+// markRemoved records that an admin removed userID from the org, so they
+// can't rejoin through a domain claim.
+func markRemoved(ctx context.Context, orgID, userID, removedBy string) error {
+	_, err := db.GetAutoJoinCollection().UpdateOne(ctx,
+		bson.M{"org_id": orgID, "user_id": userID},
+		bson.M{"$set": bson.M{"removed_at": time.Now(), "removed_by": removedBy}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// autoJoinVerifiedDomains adds the user to every org with an auto-join claim
+// on one of their verified email domains. Failures are logged and skipped.
+func autoJoinVerifiedDomains(enforcer *casbin.Enforcer, identity models.Identity) {
+	claims, err := joinableClaims(context.TODO(), identity, bson.M{"auto_join": true})
+	if err != nil {
+		fmt.Printf("Warning: Failed to look up auto-join domains: %v\n", err)
+		return
+	}
+	for _, claim := range claims {
+		// The record is claimed first, so a user who left or was removed
+		// isn't added back, and concurrent calls don't both join.
+		_, err := db.GetAutoJoinCollection().InsertOne(context.TODO(), models.AutoJoin{
+			OrgID:    claim.OrgID,
+			UserID:   identity.ID,
+			Domain:   claim.Domain,
+			JoinedAt: time.Now(),
+		})
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			fmt.Printf("Warning: Failed to record auto-join of %s to org %s: %v\n", identity.ID, claim.OrgID, err)
+			continue
+		}
+		if err := grantMembership(context.TODO(), enforcer, claim.OrgID, identity.ID, claim.DefaultRole, "domain:"+claim.Domain); err != nil {
+			// Let a later call try again, e.g. once the plan has room.
+			_, _ = db.GetAutoJoinCollection().DeleteOne(context.TODO(), bson.M{"org_id": claim.OrgID, "user_id": identity.ID})
+			fmt.Printf("Warning: Failed to auto-join %s to org %s: %v\n", identity.ID, claim.OrgID, err)
+			continue
+		}
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.member_auto_joined",
+			ActorID:  identity.ID,
+			TargetID: identity.ID,
+			Domain:   claim.OrgID,
+			Details:  map[string]interface{}{"domain": claim.Domain, "role": claim.DefaultRole},
+		})
+	}
+}
+
+func ClaimDomainHandler(c *gin.Context) {
+	var input struct {
+		Domain      string `json:"domain" binding:"required"`
+		DefaultRole string `json:"default_role"`
+		AutoJoin    bool   `json:"auto_join"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+		return
+	}
+	domain := dnsverify.NormalizeDomain(input.Domain)
+	if domain == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain"})
+		return
+	}
+	if input.DefaultRole == "" {
+		input.DefaultRole = "reader"
+	}
+	if !slices.Contains(domainJoinRoles, input.DefaultRole) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Default role must be writer or reader"})
+		return
+	}
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	orgID := c.Param("id")
+	if !primitive.IsValidObjectID(orgID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	taken, err := db.GetDomainClaimCollection().CountDocuments(context.TODO(),
+		bson.M{"domain": domain, "verified": true, "org_id": bson.M{"$ne": orgID}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if taken > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "This domain is already verified by another organization"})
+		return
+	}
+
+	token, err := dnsverify.NewToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate verification token"})
+		return
+	}
+	actorID := user.(models.Identity).ID
+	var claim models.DomainClaim
+	err = db.GetDomainClaimCollection().FindOneAndUpdate(
+		context.TODO(),
+		bson.M{"org_id": orgID, "domain": domain},
+		bson.M{
+			"$set": bson.M{"default_role": input.DefaultRole, "auto_join": input.AutoJoin},
+			"$setOnInsert": bson.M{
+				"token":      token,
+				"verified":   false,
+				"created_by": actorID,
+				"created_at": time.Now(),
+			},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&claim)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to claim domain"})
+		return
+	}
+
+	_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+		Action:   "org.domain_claimed",
+		ActorID:  actorID,
+		TargetID: domain,
+		Domain:   orgID,
+		Details:  map[string]interface{}{"default_role": claim.DefaultRole, "auto_join": claim.AutoJoin},
+	})
+	c.JSON(http.StatusOK, newDomainClaimView(claim))
+}
+
+// VerifyDomainHandler looks for the claim's TXT record and marks the domain
+// verified when it is published.
+func VerifyDomainHandler(c *gin.Context) {
+	var input struct {
+		Domain string `json:"domain" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+		return
+	}
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	orgID := c.Param("id")
+	domain := dnsverify.NormalizeDomain(input.Domain)
+
+	var claim models.DomainClaim
+	err := db.GetDomainClaimCollection().FindOne(context.TODO(), bson.M{"org_id": orgID, "domain": domain}).Decode(&claim)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Domain has not been claimed"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return
+	}
+	if claim.Verified {
+		c.JSON(http.StatusOK, newDomainClaimView(claim))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ok, err := dnsverify.Verify(ctx, claim.Domain, claim.Token)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "DNS lookup failed: " + err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":        "Verification record not found",
+			"record_name":  dnsverify.RecordName(claim.Domain),
+			"record_value": dnsverify.RecordValue(claim.Token),
+		})
+		return
+	}
+
+	now := time.Now()
+	err = db.GetDomainClaimCollection().FindOneAndUpdate(
+		context.TODO(),
+		bson.M{"_id": claim.ID},
+		bson.M{"$set": bson.M{"verified": true, "verified_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&claim)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "This domain is already verified by another organization"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify domain"})
+		}
+		return
+	}
+
+	_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+		Action:   "org.domain_verified",
+		ActorID:  user.(models.Identity).ID,
+		TargetID: claim.Domain,
+		Domain:   orgID,
+	})
+	c.JSON(http.StatusOK, newDomainClaimView(claim))
+}
+
+func GetDomainsHandler(c *gin.Context) {
+	cursor, err := db.GetDomainClaimCollection().Find(context.TODO(), bson.M{"org_id": c.Param("id")},
+		options.Find().SetSort(bson.D{{Key: "domain", Value: 1}}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve domains"})
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	var claims []models.DomainClaim
+	if err := cursor.All(context.TODO(), &claims); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode domains"})
+		return
+	}
+	views := make([]domainClaimView, 0, len(claims))
+	for _, claim := range claims {
+		views = append(views, newDomainClaimView(claim))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": views})
+}
+
+func DeleteDomainHandler(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	orgID := c.Param("id")
+	domain := dnsverify.NormalizeDomain(c.Query("domain"))
+
+	res, err := db.GetDomainClaimCollection().DeleteOne(context.TODO(), bson.M{"org_id": orgID, "domain": domain})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove domain"})
+		return
+	}
+	if res.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Domain has not been claimed"})
+		return
+	}
+
+	_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+		Action:   "org.domain_removed",
+		ActorID:  user.(models.Identity).ID,
+		TargetID: domain,
+		Domain:   orgID,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Domain removed"})
+}
+
+// GetJoinableOrgsHandler lists the orgs the user may join because of a
+// verified email domain.
+func GetJoinableOrgsHandler(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+
+	claims, err := joinableClaims(context.TODO(), user.(models.Identity), bson.M{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve joinable organizations"})
+		return
+	}
+
+	type joinableOrg struct {
+		Org    models.Organization `json:"org"`
+		Domain string              `json:"domain"`
+		Role   string              `json:"role"`
+	}
+	joinable := []joinableOrg{}
+	for _, claim := range claims {
+		objID, err := primitive.ObjectIDFromHex(claim.OrgID)
+		if err != nil {
+			continue
+		}
+		var org models.Organization
+		if err := db.GetOrgCollection().FindOne(context.TODO(), bson.M{"_id": objID}).Decode(&org); err != nil || org.ArchivedAt != nil {
+			continue
+		}
+		joinable = append(joinable, joinableOrg{Org: org, Domain: claim.Domain, Role: claim.DefaultRole})
+	}
+	c.JSON(http.StatusOK, gin.H{"data": joinable})
+}
+
+// JoinOrgHandler adds the user to an org whose verified domain matches one
+// of their verified email addresses, unless an admin removed them from it.
+func JoinOrgHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			OrgID string `json:"org_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+			return
+		}
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		identity := user.(models.Identity)
+
+		orgID, err := db.ResolveOrgID(context.TODO(), input.OrgID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+		claims, err := joinableClaims(context.TODO(), identity, bson.M{"org_id": orgID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if len(claims) == 0 {
+			if _, err := getMembership(context.TODO(), orgID, identity.ID); err == nil {
+				c.JSON(http.StatusConflict, gin.H{"error": errAlreadyMember.Error()})
+				return
+			}
+			c.JSON(http.StatusForbidden, gin.H{"error": "Your verified email does not match a domain verified by this organization"})
+			return
+		}
+		claim := claims[0]
+
+		// Recorded like an auto-join, so it isn't repeated after they leave.
+		_, err = db.GetAutoJoinCollection().UpdateOne(context.TODO(),
+			bson.M{"org_id": orgID, "user_id": identity.ID},
+			bson.M{"$setOnInsert": models.AutoJoin{OrgID: orgID, UserID: identity.ID, Domain: claim.Domain, JoinedAt: time.Now()}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join organization"})
+			return
+		}
+		err = grantMembership(context.TODO(), enforcer, orgID, identity.ID, claim.DefaultRole, "domain:"+claim.Domain)
+		if err != nil {
+			respondGrantError(c, err, "Failed to join organization")
+			return
+		}
+
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.member_joined",
+			ActorID:  identity.ID,
+			TargetID: identity.ID,
+			Domain:   orgID,
+			Details:  map[string]interface{}{"domain": claim.Domain, "role": claim.DefaultRole},
+		})
+		c.JSON(http.StatusOK, gin.H{"message": "Joined organization", "org_id": orgID, "role": claim.DefaultRole})
+	}
+}
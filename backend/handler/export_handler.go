@@ -0,0 +1,716 @@
+package handler
+
+import (
+	"backend/db"
+	"backend/dnsverify"
+	"backend/logostore"
+	"backend/models"
+	"backend/orgarchive"
+	"backend/orgsettings"
+	"backend/plans"
+	"backend/temporal/workflows"
+	"backend/utils"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.temporal.io/sdk/client"
+)
+
+type importSkip struct {
+	Email  string `json:"email"`
+	Reason string `json:"reason"`
+}
+
+func findAll[T any](ctx context.Context, collection *mongo.Collection, filter bson.M) ([]T, error) {
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	items := []T{}
+	if err := cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// exportSubject rewrites a rule subject for the archive. Users become
+// "user:<email>"; users without a Kratos identity are dropped. Roles, teams
+// and projects keep their names, which the import remaps.
+func exportSubject(subject string, dir map[string]models.Identity) (string, bool) {
+	if _, err := uuid.Parse(subject); err != nil {
+		return subject, true
+	}
+	identity, ok := dir[subject]
+	if !ok || identity.Traits.Email == "" {
+		return "", false
+	}
+	return orgarchive.UserPrefix + strings.ToLower(identity.Traits.Email), true
+}
+
+func exportObject(obj, orgID string) string {
+	if strings.HasSuffix(obj, "/"+orgID) {
+		return strings.TrimSuffix(obj, orgID) + orgarchive.OrgPlaceholder
+	}
+	return obj
+}
+
+func buildOrgArchive(ctx context.Context, enforcer *casbin.Enforcer, org models.Organization, exportedBy string) (*orgarchive.Archive, error) {
+	orgID := org.ID.Hex()
+	dir, err := identityDirectory()
+	if err != nil {
+		return nil, err
+	}
+	email := func(userID string) string {
+		return strings.ToLower(dir[userID].Traits.Email)
+	}
+
+	a := &orgarchive.Archive{
+		Manifest: orgarchive.Manifest{
+			FormatVersion: orgarchive.FormatVersion,
+			SourceOrgID:   orgID,
+			ExportedBy:    exportedBy,
+			ExportedAt:    time.Now(),
+		},
+		Org: orgarchive.Org{
+			Name:        org.Name,
+			Slug:        org.Slug,
+			Description: org.Description,
+			CreatedAt:   org.CreatedAt,
+		},
+		Settings: orgarchive.Settings{
+			Visibility: orgVisibility(org),
+			Plan:       org.Plan,
+			Domains:    []orgarchive.DomainClaim{},
+		},
+		Members:  []orgarchive.Member{},
+		Teams:    []orgarchive.Team{},
+		Projects: []orgarchive.Project{},
+		Policies: orgarchive.Policies{P: [][]string{}, G: [][]string{}},
+	}
+
+	if org.Settings != nil {
+		exportBranding(ctx, org, a)
+	}
+
+	memberships, err := findMemberships(ctx, bson.M{"org_id": orgID})
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range memberships {
+		if email(m.UserID) == "" {
+			continue
+		}
+		a.Members = append(a.Members, orgarchive.Member{
+			Email:    email(m.UserID),
+			Name:     dir[m.UserID].Traits.Name,
+			Role:     m.Role,
+			JoinedAt: m.JoinedAt,
+		})
+	}
+
+	teams, err := findAll[models.Team](ctx, db.GetTeamCollection(), bson.M{"org_id": orgID})
+	if err != nil {
+		return nil, err
+	}
+	for _, team := range teams {
+		members, err := findAll[models.TeamMember](ctx, db.GetTeamMemberCollection(), bson.M{"team_id": team.ID.Hex()})
+		if err != nil {
+			return nil, err
+		}
+		emails := []string{}
+		for _, m := range members {
+			if e := email(m.UserID); e != "" {
+				emails = append(emails, e)
+			}
+		}
+		a.Teams = append(a.Teams, orgarchive.Team{
+			Key:         team.ID.Hex(),
+			Name:        team.Name,
+			Description: team.Description,
+			Role:        team.Role,
+			Members:     emails,
+		})
+	}
+
+	projects, err := findAll[models.Project](ctx, db.GetProjectCollection(), bson.M{"org_id": orgID})
+	if err != nil {
+		return nil, err
+	}
+	for _, project := range projects {
+		members, err := findAll[models.ProjectMember](ctx, db.GetProjectMemberCollection(), bson.M{"project_id": project.ID.Hex()})
+		if err != nil {
+			return nil, err
+		}
+		exported := []orgarchive.ProjectMember{}
+		for _, m := range members {
+			if e := email(m.UserID); e != "" {
+				exported = append(exported, orgarchive.ProjectMember{Email: e, Role: m.Role})
+			}
+		}
+		a.Projects = append(a.Projects, orgarchive.Project{
+			Key:         project.ID.Hex(),
+			Name:        project.Name,
+			Description: project.Description,
+			Members:     exported,
+		})
+	}
+
+	claims, err := findAll[models.DomainClaim](ctx, db.GetDomainClaimCollection(), bson.M{"org_id": orgID})
+	if err != nil {
+		return nil, err
+	}
+	for _, claim := range claims {
+		a.Settings.Domains = append(a.Settings.Domains, orgarchive.DomainClaim{
+			Domain:      claim.Domain,
+			DefaultRole: claim.DefaultRole,
+			AutoJoin:    claim.AutoJoin,
+		})
+	}
+
+	// An archived org's write rules are frozen on the org document; they are
+	// exported as if the org were active.
+	rules, err := enforcer.GetFilteredPolicy(1, orgID)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, rule := range append(rules, org.FrozenPolicies...) {
+		if len(rule) < 4 || seen[strings.Join(rule, "|")] {
+			continue
+		}
+		seen[strings.Join(rule, "|")] = true
+		sub, ok := exportSubject(rule[0], dir)
+		if !ok {
+			continue
+		}
+		a.Policies.P = append(a.Policies.P, []string{sub, exportObject(rule[2], orgID), rule[3]})
+	}
+	grouping, err := enforcer.GetFilteredGroupingPolicy(2, orgID)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range grouping {
+		sub, ok := exportSubject(rule[0], dir)
+		if !ok {
+			continue
+		}
+		a.Policies.G = append(a.Policies.G, []string{sub, rule[1]})
+	}
+	return a, nil
+}
+
+// exportBranding adds the org's settings and uploaded logo to a. A logo that
+// can't be loaded is left out rather than failing the export.
+func exportBranding(ctx context.Context, org models.Organization, a *orgarchive.Archive) {
+	settings, err := orgsettings.Upgrade(org.Settings)
+	if err != nil {
+		settings = *org.Settings
+	}
+	branding := &orgarchive.Branding{
+		SchemaVersion: settings.SchemaVersion,
+		LogoURL:       settings.Branding.LogoURL,
+		PrimaryColor:  settings.Branding.PrimaryColor,
+		AccentColor:   settings.Branding.AccentColor,
+		LandingPage:   settings.LandingPage,
+		ContactEmail:  settings.ContactEmail,
+	}
+	if key := settings.Branding.LogoKey; key != "" {
+		data, contentType, err := logostore.Get(ctx, key)
+		if err != nil {
+			fmt.Printf("Warning: Failed to export logo of org %s: %v\n", org.ID.Hex(), err)
+		} else {
+			if settings.Branding.LogoContentType != "" {
+				contentType = settings.Branding.LogoContentType
+			}
+			a.Logo = data
+			branding.LogoContentType = contentType
+		}
+	}
+	a.Settings.Branding = branding
+}
+
+// importBranding saves the archived settings for the new org, storing an
+// uploaded logo again. Settings from a newer schema, invalid settings and
+// logos of an unaccepted type are rejected.
+func importBranding(ctx context.Context, org primitive.ObjectID, archive *orgarchive.Archive, userID string) error {
+	b := archive.Settings.Branding
+	if b.SchemaVersion > orgsettings.SchemaVersion {
+		return orgsettings.ErrNewerSchema
+	}
+	settings := models.OrgSettings{
+		SchemaVersion: orgsettings.SchemaVersion,
+		Branding: models.OrgBranding{
+			LogoURL:      b.LogoURL,
+			PrimaryColor: b.PrimaryColor,
+			AccentColor:  b.AccentColor,
+		},
+		LandingPage:  b.LandingPage,
+		ContactEmail: b.ContactEmail,
+	}
+	if err := orgsettings.Normalize(&settings); err != nil {
+		return err
+	}
+	if len(archive.Logo) > 0 {
+		contentType := http.DetectContentType(archive.Logo)
+		ext, ok := logoTypes[contentType]
+		if !ok || len(archive.Logo) > maxLogoSize {
+			return errors.New("unsupported logo")
+		}
+		key := org.Hex() + "/" + uuid.NewString() + ext
+		if err := logostore.Put(ctx, key, contentType, archive.Logo); err != nil {
+			return err
+		}
+		settings.Branding.LogoURL = ""
+		settings.Branding.LogoKey = key
+		settings.Branding.LogoContentType = contentType
+	}
+	_, err := saveSettings(ctx, org, settings, userID)
+	return err
+}
+
+// ExportOrgHandler downloads the org as a zip archive: the org document,
+// settings, members with their roles, teams, projects and the org's Casbin
+// rules. Secrets are not exported since they are encrypted for this
+// deployment only.
+func ExportOrgHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		org, ok := findOrg(c)
+		if !ok {
+			return
+		}
+		actorID := user.(models.Identity).ID
+
+		archive, err := buildOrgArchive(context.TODO(), enforcer, org, user.(models.Identity).Traits.Email)
+		if err != nil {
+			fmt.Printf("Warning: Failed to export org %s: %v\n", org.ID.Hex(), err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export organization"})
+			return
+		}
+		var buf bytes.Buffer
+		if err := orgarchive.Write(&buf, archive); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write archive"})
+			return
+		}
+
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.exported",
+			ActorID:  actorID,
+			TargetID: org.ID.Hex(),
+			Domain:   org.ID.Hex(),
+		})
+		filename := fmt.Sprintf("org-%s-%s.zip", org.Slug, time.Now().Format("20060102"))
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Data(http.StatusOK, "application/zip", buf.Bytes())
+	}
+}
+
+// orgImport maps the archive's teams and projects to the ones created in the
+// new org.
+type orgImport struct {
+	orgID    string
+	teams    map[string]string
+	projects map[string]string
+}
+
+// subject maps an archived p rule subject into the new org. It reports false
+// for users and built-in roles: the importer is already admin, and built-in
+// roles get the current rules from orgPolicies. Custom roles keep their name;
+// nobody holds them until an admin assigns them.
+func (m orgImport) subject(s string) (string, bool) {
+	switch {
+	case strings.HasPrefix(s, orgarchive.UserPrefix):
+		return "", false
+	case isTeamSubject(s):
+		id, ok := m.teams[strings.TrimPrefix(s, "team:")]
+		return teamSubject(id), ok
+	case isProjectRole(s):
+		// Either a project object "project:<key>" or a role
+		// "project:<key>:<role>".
+		parts := strings.SplitN(strings.TrimPrefix(s, "project:"), ":", 2)
+		id, ok := m.projects[parts[0]]
+		if len(parts) == 2 {
+			return projectRole(id, parts[1]), ok
+		}
+		return projectObject(id), ok
+	}
+	return s, isCustomRoleName(s)
+}
+
+// isCustomRoleName reports whether s can name a custom role: not built in,
+// not a user ID and free of the separators used by links.
+func isCustomRoleName(s string) bool {
+	_, err := uuid.Parse(s)
+	return s != "" && err != nil && !plans.IsBuiltinRole(s) && !strings.Contains(s, ":")
+}
+
+func (m orgImport) object(obj string) (string, bool) {
+	if isProjectRole(obj) {
+		return m.subject(obj)
+	}
+	return strings.ReplaceAll(obj, orgarchive.OrgPlaceholder, m.orgID), true
+}
+
+// limitCustomRoles drops the rules whose subject would be a new custom role
+// when the org's plan has no room for them. It returns the rules to keep and
+// how many were dropped.
+func limitCustomRoles(ctx context.Context, orgID string, rules [][]string) ([][]string, int) {
+	existing, err := plans.CustomRoles(ctx, orgID)
+	if err != nil {
+		fmt.Printf("Warning: Failed to count custom roles of org %s: %v\n", orgID, err)
+	}
+	isNew := func(sub string) bool {
+		return isCustomRoleName(sub) && !slices.Contains(existing, sub)
+	}
+	var added []string
+	for _, rule := range rules {
+		if isNew(rule[0]) && !slices.Contains(added, rule[0]) {
+			added = append(added, rule[0])
+		}
+	}
+	if len(added) == 0 {
+		return rules, 0
+	}
+	if err := plans.CheckN(ctx, orgID, plans.LimitCustomRoles, int64(len(added))); err == nil {
+		return rules, 0
+	}
+	kept := rules[:0]
+	for _, rule := range rules {
+		if !isNew(rule[0]) {
+			kept = append(kept, rule)
+		}
+	}
+	return kept, len(rules) - len(kept)
+}
+
+// archiveBody returns the uploaded archive, sent either as the "file" field of
+// a multipart form or as the raw request body.
+func archiveBody(c *gin.Context) ([]byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, orgarchive.MaxSize)
+	var r io.ReadCloser = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		if r, err = header.Open(); err != nil {
+			return nil, err
+		}
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// ImportOrgHandler recreates an exported org in this deployment, owned by the
+// caller, who is the only member added directly. The other members are
+// matched by email and invited with their old role (and first team); those
+// without an account here are skipped and reported. Project memberships are
+// only restored for the caller. The plan is not imported, and domain claims
+// have to be verified again. ?name= renames the org.
+func ImportOrgHandler(enforcer *casbin.Enforcer, temporalClient client.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		identity := user.(models.Identity)
+		ctx := context.TODO()
+
+		data, err := archiveBody(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or oversized archive"})
+			return
+		}
+		archive, err := orgarchive.Read(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		name := strings.TrimSpace(c.DefaultQuery("name", archive.Org.Name))
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Organization name cannot be empty"})
+			return
+		}
+		taken, err := db.OrgNameTaken(ctx, name, primitive.NilObjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import organization"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": "An organization with this name already exists"})
+			return
+		}
+		slug, err := db.UniqueOrgSlug(ctx, name, primitive.NilObjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import organization"})
+			return
+		}
+		visibility := archive.Settings.Visibility
+		if !slices.Contains(orgVisibilities, visibility) {
+			visibility = visibilityPrivate
+		}
+		// Everything that can fail without side effects happens before the
+		// org is created, so a failed import leaves nothing behind.
+		identities, err := fetchIdentities()
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		known := make(map[string]bool, len(identities))
+		for _, i := range identities {
+			known[strings.ToLower(i.Traits.Email)] = true
+		}
+
+		org := models.Organization{
+			Name:        name,
+			Slug:        slug,
+			Description: archive.Org.Description,
+			CreatedBy:   identity.ID,
+			CreatedAt:   time.Now(),
+			Plan:        plans.Default,
+			Visibility:  visibility,
+		}
+		res, err := db.GetOrgCollection().InsertOne(ctx, org)
+		if err != nil {
+			if db.IsDuplicateOrgName(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "An organization with this name already exists"})
+			} else if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Organization slug already in use, please retry"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import organization"})
+			}
+			return
+		}
+		org.ID = res.InsertedID.(primitive.ObjectID)
+		orgID := org.ID.Hex()
+
+		if err := addMembership(ctx, orgID, identity.ID, "admin", ""); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add organization admin"})
+			return
+		}
+		if err := initOrgDomain(enforcer, orgID, identity.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign policies"})
+			return
+		}
+
+		mapping := orgImport{
+			orgID:    orgID,
+			teams:    map[string]string{},
+			projects: map[string]string{},
+		}
+		importerEmail := strings.ToLower(identity.Traits.Email)
+
+		// Teams keep their role; membership is restored for the importer
+		// right away and for everyone else when they accept their invite.
+		firstTeam := map[string]string{}
+		for _, t := range archive.Teams {
+			if !slices.Contains(teamRoles, t.Role) {
+				fmt.Printf("Warning: Skipping team %q with unknown role %q\n", t.Name, t.Role)
+				continue
+			}
+			team := models.Team{
+				OrgID:       orgID,
+				Name:        t.Name,
+				Description: t.Description,
+				Role:        t.Role,
+				CreatedBy:   identity.ID,
+				CreatedAt:   time.Now(),
+			}
+			res, err := db.GetTeamCollection().InsertOne(ctx, team)
+			if err != nil {
+				fmt.Printf("Warning: Failed to import team %q: %v\n", t.Name, err)
+				continue
+			}
+			team.ID = res.InsertedID.(primitive.ObjectID)
+			if _, err := enforcer.AddRoleForUserInDomain(teamSubject(team.ID.Hex()), team.Role, orgID); err != nil {
+				_, _ = db.GetTeamCollection().DeleteOne(ctx, bson.M{"_id": team.ID})
+				fmt.Printf("Warning: Failed to assign role of team %q: %v\n", t.Name, err)
+				continue
+			}
+			mapping.teams[t.Key] = team.ID.Hex()
+			for _, email := range t.Members {
+				email = strings.ToLower(email)
+				if email == importerEmail {
+					if err := joinTeam(ctx, enforcer, team, identity.ID, identity.ID); err != nil {
+						fmt.Printf("Warning: Failed to add importer to team %q: %v\n", t.Name, err)
+					}
+				} else if _, ok := firstTeam[email]; !ok {
+					firstTeam[email] = team.ID.Hex()
+				}
+			}
+		}
+
+		for _, p := range archive.Projects {
+			project := models.Project{
+				OrgID:       orgID,
+				Name:        p.Name,
+				Description: p.Description,
+				CreatedBy:   identity.ID,
+				CreatedAt:   time.Now(),
+			}
+			res, err := db.GetProjectCollection().InsertOne(ctx, project)
+			if err != nil {
+				fmt.Printf("Warning: Failed to import project %q: %v\n", p.Name, err)
+				continue
+			}
+			projectID := res.InsertedID.(primitive.ObjectID).Hex()
+			policies, grouping := projectPolicies(orgID, projectID)
+			if _, err := enforcer.AddPolicies(policies); err != nil {
+				fmt.Printf("Warning: Failed to assign policies of project %q: %v\n", p.Name, err)
+				continue
+			}
+			if _, err := enforcer.AddNamedGroupingPolicies("g", grouping); err != nil {
+				fmt.Printf("Warning: Failed to assign roles of project %q: %v\n", p.Name, err)
+				continue
+			}
+			mapping.projects[p.Key] = projectID
+			for _, m := range p.Members {
+				if strings.ToLower(m.Email) != importerEmail || !slices.Contains(projectRoles, m.Role) {
+					continue
+				}
+				_, err := db.GetProjectMemberCollection().InsertOne(ctx, models.ProjectMember{
+					ProjectID: projectID, OrgID: orgID, UserID: identity.ID, Role: m.Role, AddedBy: identity.ID, AddedAt: time.Now(),
+				})
+				if err == nil {
+					_, err = enforcer.AddRoleForUserInDomain(identity.ID, projectRole(projectID, m.Role), orgID)
+				}
+				if err != nil {
+					fmt.Printf("Warning: Failed to add importer to project %q: %v\n", p.Name, err)
+				}
+			}
+		}
+
+		// Everyone else gets an invite through the usual flow, so nobody is
+		// added to an org without accepting.
+		invited := []string{}
+		skipped := []importSkip{}
+		for _, member := range archive.Members {
+			email := strings.ToLower(member.Email)
+			if email == importerEmail || slices.Contains(invited, email) {
+				continue
+			}
+			if !known[email] {
+				skipped = append(skipped, importSkip{Email: email, Reason: "no account with this email"})
+				continue
+			}
+			if !slices.Contains(teamRoles, member.Role) {
+				skipped = append(skipped, importSkip{Email: email, Reason: "unknown role " + member.Role})
+				continue
+			}
+			_, err := temporalClient.ExecuteWorkflow(
+				context.Background(),
+				client.StartWorkflowOptions{
+					ID:        fmt.Sprintf("novu-invite-%s", uuid.NewString()),
+					TaskQueue: "NOVU_INVITE_QUEUE",
+				},
+				workflows.NovuInviteWorkflow,
+				models.CreateInvite{
+					OrgID:       orgID,
+					OrgName:     org.Name,
+					Email:       email,
+					Description: org.Description,
+					UserId:      identity.ID,
+					Role:        member.Role,
+					TeamID:      firstTeam[email],
+				},
+			)
+			if err != nil {
+				skipped = append(skipped, importSkip{Email: email, Reason: "failed to send invite"})
+				continue
+			}
+			invited = append(invited, email)
+		}
+
+		if archive.Settings.Branding != nil {
+			if err := importBranding(ctx, org.ID, archive, identity.ID); err != nil {
+				fmt.Printf("Warning: Failed to import settings for org %s: %v\n", orgID, err)
+			}
+		}
+
+		for _, d := range archive.Settings.Domains {
+			token, err := dnsverify.NewToken()
+			if err != nil {
+				continue
+			}
+			_, _ = db.GetDomainClaimCollection().InsertOne(ctx, models.DomainClaim{
+				OrgID:       orgID,
+				Domain:      d.Domain,
+				Token:       token,
+				DefaultRole: d.DefaultRole,
+				AutoJoin:    d.AutoJoin,
+				CreatedBy:   identity.ID,
+				CreatedAt:   time.Now(),
+			})
+		}
+
+		// Role links were rebuilt above, so only p rules granted to the
+		// imported teams and projects and to custom roles are taken from the
+		// archive, the latter within the plan's custom role limit. Everything
+		// else in policies.json is ignored.
+		var policies [][]string
+		for _, rule := range archive.Policies.P {
+			if len(rule) != 3 {
+				continue
+			}
+			sub, ok := mapping.subject(rule[0])
+			obj, objOK := mapping.object(rule[1])
+			if !ok || !objOK {
+				continue
+			}
+			mapped := []string{sub, orgID, obj, rule[2]}
+			if has, _ := enforcer.HasPolicy(mapped); !has {
+				policies = append(policies, mapped)
+			}
+		}
+		policies, dropped := limitCustomRoles(ctx, orgID, policies)
+		if len(policies) > 0 {
+			if _, err := enforcer.AddPolicies(policies); err != nil {
+				fmt.Printf("Warning: Failed to import rules for org %s: %v\n", orgID, err)
+			}
+		}
+
+		_ = utils.RecordAudit(ctx, models.AuditEntry{
+			Action:   "org.imported",
+			ActorID:  identity.ID,
+			TargetID: orgID,
+			Domain:   orgID,
+			Details: map[string]interface{}{
+				"source_org_id": archive.Manifest.SourceOrgID,
+				"invited":       len(invited),
+				"skipped":       len(skipped),
+			},
+		})
+		c.JSON(http.StatusCreated, gin.H{
+			"org": org,
+			"imported": gin.H{
+				"teams":    len(mapping.teams),
+				"projects": len(mapping.projects),
+				"rules":    len(policies),
+			},
+			"dropped_rules": dropped,
+			"invited":       invited,
+			"skipped":       skipped,
+		})
+	}
+}
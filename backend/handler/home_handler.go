@@ -1,13 +1,17 @@
 package handler
 
 import (
+	"backend/db"
 	"backend/models"
+	"backend/plans"
 	"backend/temporal/workflows"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 
+	"github.com/casbin/casbin/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/google/go-github/v55/github"
 	"github.com/google/uuid"
@@ -86,12 +90,17 @@ func GitHubRepos(c *gin.Context) {
 	c.JSON(http.StatusOK, repos)
 }
 
-func CreateRepoHandler(temporalClient client.Client) gin.HandlerFunc {
+// CreateRepoHandler creates a GitHub repo for the user. When org_id is given
+// the repo is recorded against the org, which needs writer access there and
+// counts towards the org's repo limit.
+func CreateRepoHandler(temporalClient client.Client, enforcer *casbin.Enforcer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var body struct {
 			Name        string `json:"name"`
 			Description string `json:"description"`
 			Private     bool   `json:"private"`
+			OrgID       string `json:"org_id"`
+			ProjectID   string `json:"project_id"`
 		}
 
 		if err := c.BindJSON(&body); err != nil {
@@ -105,6 +114,41 @@ func CreateRepoHandler(temporalClient client.Client) gin.HandlerFunc {
 			return
 		}
 
+		userID := ""
+		if user, exists := c.Get("user"); exists {
+			userID = user.(models.Identity).ID
+		}
+		orgID := ""
+		if body.OrgID != "" {
+			orgID, err = db.ResolveOrgID(context.TODO(), body.OrgID)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+				return
+			}
+			roles, _ := enforcer.GetImplicitRolesForUser(userID, orgID)
+			if !slices.Contains(roles, "writer") {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+				return
+			}
+			if archived, err := orgArchived(context.TODO(), orgID); err != nil || archived {
+				c.JSON(http.StatusConflict, gin.H{"error": errOrgArchived.Error()})
+				return
+			}
+			if !checkLimits(c, orgID, plans.LimitRepos) {
+				return
+			}
+		}
+		if body.ProjectID != "" {
+			if orgID == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "project_id requires org_id"})
+				return
+			}
+			project, ok := findProject(c, orgID, body.ProjectID)
+			if !ok || !authorizeProject(c, enforcer, project, projectWrite) {
+				return
+			}
+		}
+
 		workflowID := fmt.Sprintf("create-repo-%s", uuid.NewString())
 
 		we, err := temporalClient.ExecuteWorkflow(
@@ -119,6 +163,9 @@ func CreateRepoHandler(temporalClient client.Client) gin.HandlerFunc {
 				Description: body.Description,
 				Private:     body.Private,
 				GithubToken: token,
+				OrgID:       orgID,
+				ProjectID:   body.ProjectID,
+				CreatedBy:   userID,
 			},
 		)
 
@@ -129,6 +176,9 @@ func CreateRepoHandler(temporalClient client.Client) gin.HandlerFunc {
 
 		var repo *github.Repository
 		if err := we.Get(context.Background(), &repo); err != nil {
+			if respondLimitError(c, err) {
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
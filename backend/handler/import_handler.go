@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"backend/db"
+	"backend/models"
+	"backend/plans"
+	"backend/temporal/workflows"
+	"backend/utils"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.temporal.io/sdk/client"
+)
+
+const (
+	maxImportRows  = 1000
+	maxImportBytes = 1 << 20
+)
+
+type importRowError struct {
+	Row   int    `json:"row"`
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
+
+func importWorkflowID(orgID, importID string) string {
+	return fmt.Sprintf("bulk-import-%s-%s", orgID, importID)
+}
+
+// importFile returns the uploaded CSV, sent either as the "file" field of a
+// multipart form or as the raw request body.
+func importFile(c *gin.Context) (io.ReadCloser, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		return header.Open()
+	}
+	return c.Request.Body, nil
+}
+
+// parseImportCSV reads the email, role and team columns and validates every
+// row, returning all row errors at once. Teams may be given by name or ID.
+func parseImportCSV(r io.Reader, teams map[string]models.Team) ([]models.BulkImportRow, []importRowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, errors.New("CSV file is empty or unreadable")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, nil, errors.New("CSV must have an email column")
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []models.BulkImportRow
+	var rowErrors []importRowError
+	seen := map[string]int{}
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			rowErrors = append(rowErrors, importRowError{Row: line, Error: "Malformed CSV line"})
+			continue
+		}
+
+		row := models.BulkImportRow{
+			Row:   line,
+			Email: strings.ToLower(field(record, "email")),
+			Role:  strings.ToLower(field(record, "role")),
+			Team:  field(record, "team"),
+		}
+		if row.Role == "" {
+			row.Role = "reader"
+		}
+
+		var problems []string
+		if address, err := mail.ParseAddress(row.Email); err != nil || address.Address != row.Email {
+			problems = append(problems, "invalid email")
+		} else if first, ok := seen[row.Email]; ok {
+			problems = append(problems, "duplicate of row "+strconv.Itoa(first))
+		} else {
+			seen[row.Email] = line
+		}
+		if !slices.Contains(teamRoles, row.Role) {
+			problems = append(problems, "role must be one of admin, writer or reader")
+		}
+		if row.Team != "" {
+			if team, ok := teams[strings.ToLower(row.Team)]; ok {
+				row.TeamID = team.ID.Hex()
+			} else {
+				problems = append(problems, "unknown team "+strconv.Quote(row.Team))
+			}
+		}
+
+		if len(problems) > 0 {
+			rowErrors = append(rowErrors, importRowError{Row: line, Email: row.Email, Error: strings.Join(problems, "; ")})
+			continue
+		}
+		rows = append(rows, row)
+	}
+	if len(rows)+len(rowErrors) > maxImportRows {
+		return nil, nil, fmt.Errorf("CSV has more than %d rows", maxImportRows)
+	}
+	return rows, rowErrors, nil
+}
+
+// orgTeamIndex maps the org's team names (lowercased) and IDs to the team.
+func orgTeamIndex(ctx context.Context, orgID string) (map[string]models.Team, error) {
+	cursor, err := db.GetTeamCollection().Find(ctx, bson.M{"org_id": orgID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var teams []models.Team
+	if err := cursor.All(ctx, &teams); err != nil {
+		return nil, err
+	}
+	index := make(map[string]models.Team, len(teams)*2)
+	for _, team := range teams {
+		index[strings.ToLower(team.Name)] = team
+		index[team.ID.Hex()] = team
+	}
+	return index, nil
+}
+
+// ImportMembersHandler validates a member CSV and starts a bulk invite. Any
+// invalid row rejects the whole file so nothing is half-imported by mistake.
+func ImportMembersHandler(temporalClient client.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		org, ok := findOrg(c)
+		if !ok {
+			return
+		}
+		orgID := org.ID.Hex()
+
+		file, err := importFile(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or oversized CSV file"})
+			return
+		}
+		defer file.Close()
+
+		teams, err := orgTeamIndex(context.TODO(), orgID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load teams"})
+			return
+		}
+		rows, rowErrors, err := parseImportCSV(file, teams)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(rowErrors) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "CSV contains invalid rows", "rows": rowErrors})
+			return
+		}
+		if len(rows) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "CSV has no rows"})
+			return
+		}
+		if err := plans.CheckN(context.TODO(), orgID, plans.LimitPendingInvites, int64(len(rows))); err != nil {
+			if !respondLimitError(c, err) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check plan limits"})
+			}
+			return
+		}
+
+		actorID := user.(models.Identity).ID
+		importID := uuid.NewString()
+		we, err := temporalClient.ExecuteWorkflow(
+			context.Background(),
+			client.StartWorkflowOptions{
+				ID:        importWorkflowID(orgID, importID),
+				TaskQueue: "BULK_IMPORT_QUEUE",
+			},
+			workflows.BulkImportWorkflow,
+			models.BulkImportInput{
+				ImportID:    importID,
+				OrgID:       orgID,
+				OrgName:     org.Name,
+				Description: org.Description,
+				RequestedBy: actorID,
+				Rows:        rows,
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.members_imported",
+			ActorID:  actorID,
+			TargetID: importID,
+			Domain:   orgID,
+			Details:  map[string]interface{}{"rows": len(rows)},
+		})
+		c.JSON(http.StatusAccepted, gin.H{
+			"import_id":   importID,
+			"workflow_id": we.GetID(),
+			"total":       len(rows),
+		})
+	}
+}
+
+func queryImportProgress(c *gin.Context, temporalClient client.Client) (models.BulkImportProgress, bool) {
+	var progress models.BulkImportProgress
+	importID := c.Query("import_id")
+	if importID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "import_id is required"})
+		return progress, false
+	}
+	value, err := temporalClient.QueryWorkflow(context.Background(),
+		importWorkflowID(c.Param("id"), importID), "", workflows.BulkImportProgressQuery)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return progress, false
+	}
+	if err := value.Get(&progress); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read import progress"})
+		return progress, false
+	}
+	return progress, true
+}
+
+func GetImportStatusHandler(temporalClient client.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		progress, ok := queryImportProgress(c, temporalClient)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, progress)
+	}
+}
+
+// GetImportErrorsHandler downloads the rows that have failed so far as CSV.
+func GetImportErrorsHandler(temporalClient client.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		progress, ok := queryImportProgress(c, temporalClient)
+		if !ok {
+			return
+		}
+
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", "attachment; filename=import-"+progress.ImportID+"-errors.csv")
+		w := csv.NewWriter(c.Writer)
+		_ = w.Write([]string{"row", "email", "role", "team", "error"})
+		for _, result := range progress.Results {
+			if result.Status != "failed" {
+				continue
+			}
+			_ = w.Write([]string{strconv.Itoa(result.Row), result.Email, result.Role, result.Team, result.Error})
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"backend/models"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseImportCSV(t *testing.T) {
+	core := models.Team{ID: primitive.NewObjectID(), Name: "Core"}
+	teams := map[string]models.Team{"core": core, core.ID.Hex(): core}
+
+	tests := []struct {
+		name       string
+		csv        string
+		wantRows   []models.BulkImportRow
+		wantErrors []importRowError
+		wantErr    string
+	}{
+		{
+			name: "valid rows",
+			csv: "Email,Role,Team\n" +
+				"Ann@Example.com,Writer,core\n" +
+				"bob@example.com,,\n" +
+				"cy@example.com,admin," + core.ID.Hex() + "\n",
+			wantRows: []models.BulkImportRow{
+				{Row: 2, Email: "ann@example.com", Role: "writer", Team: "core", TeamID: core.ID.Hex()},
+				{Row: 3, Email: "bob@example.com", Role: "reader"},
+				{Row: 4, Email: "cy@example.com", Role: "admin", Team: core.ID.Hex(), TeamID: core.ID.Hex()},
+			},
+		},
+		{
+			name:     "columns in any order, team optional",
+			csv:      "role,email\nreader,dee@example.com\n",
+			wantRows: []models.BulkImportRow{{Row: 2, Email: "dee@example.com", Role: "reader"}},
+		},
+		{
+			name: "row errors",
+			csv: "email,role,team\n" +
+				"not-an-email,reader,\n" +
+				"eve@example.com,owner,\n" +
+				"fay@example.com,reader,ops\n" +
+				"eve@example.com,reader,\n",
+			wantRows: []models.BulkImportRow{},
+			wantErrors: []importRowError{
+				{Row: 2, Email: "not-an-email", Error: "invalid email"},
+				{Row: 3, Email: "eve@example.com", Error: "role must be one of admin, writer or reader"},
+				{Row: 4, Email: "fay@example.com", Error: `unknown team "ops"`},
+				{Row: 5, Email: "eve@example.com", Error: "duplicate of row 3"},
+			},
+		},
+		{
+			name:    "empty file",
+			csv:     "",
+			wantErr: "CSV file is empty or unreadable",
+		},
+		{
+			name:    "no email column",
+			csv:     "name,role\nAnn,reader\n",
+			wantErr: "CSV must have an email column",
+		},
+		{
+			name:    "too many rows",
+			csv:     "email\n" + strings.Repeat("x@example.com\n", maxImportRows+1),
+			wantErr: "CSV has more than",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, rowErrors, err := parseImportCSV(strings.NewReader(tt.csv), teams)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseImportCSV() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseImportCSV() error = %v", err)
+			}
+			if len(rows) != len(tt.wantRows) || (len(rows) > 0 && !reflect.DeepEqual(rows, tt.wantRows)) {
+				t.Errorf("rows = %+v, want %+v", rows, tt.wantRows)
+			}
+			if !reflect.DeepEqual(rowErrors, tt.wantErrors) {
+				t.Errorf("row errors = %+v, want %+v", rowErrors, tt.wantErrors)
+			}
+		})
+	}
+}
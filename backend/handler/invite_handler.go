@@ -3,12 +3,15 @@ package handler
 import (
 	"backend/db"
 	"backend/models"
+	"backend/plans"
+	"backend/policy"
 	"backend/temporal/workflows"
 	"backend/utils"
 	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/casbin/casbin/v2"
 	"github.com/gin-gonic/gin"
@@ -31,6 +34,9 @@ func InviteUserHandler(temporalClient client.Client) gin.HandlerFunc {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
 			return
 		}
+		if !checkLimits(c, c.Param("id"), plans.LimitPendingInvites, plans.LimitMembers) {
+			return
+		}
 		workflowID := fmt.Sprintf("novu-invite-%s", uuid.NewString())
 		we, err := temporalClient.ExecuteWorkflow(
 			context.Background(),
@@ -40,7 +46,7 @@ func InviteUserHandler(temporalClient client.Client) gin.HandlerFunc {
 			},
 			workflows.NovuInviteWorkflow,
 			models.CreateInvite{
-				OrgID:       req.OrgID,
+				OrgID:       c.Param("id"),
 				OrgName:     req.OrgName,
 				Email:       req.Email,
 				Description: req.Description,
@@ -59,6 +65,9 @@ func InviteUserHandler(temporalClient client.Client) gin.HandlerFunc {
 		}
 		var done bool
 		if err := we.Get(context.Background(), &done); err != nil {
+			if respondLimitError(c, err) {
+				return
+			}
 			var appErr *temporal.ApplicationError
 			if errors.As(err, &appErr) {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": appErr.Message()})
@@ -188,25 +197,58 @@ func AcceptInviteHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
 			return
 		}
 
-		filter := bson.M{"_id": objID}
-		update := bson.M{
-			"$addToSet": bson.M{"users": newUser},
+		// Invites sent before invites were recorded have no document; they
+		// fall back to a plain reader membership.
+		var invite models.Invite
+		inviteFilter := bson.M{"org_id": orgID, "user_id": newUser.ID, "status": "pending"}
+		if err := db.GetInviteCollection().FindOne(context.TODO(), inviteFilter).Decode(&invite); err == nil && invite.Role != "" {
+			newUser.Role = invite.Role
 		}
-		_, err = collection.UpdateOne(context.TODO(), filter, update)
-		if err != nil {
+
+		if err := policy.CheckSeparation(enforcer, newUser.ID, newUser.Role, orgID, true); err != nil {
+			abortWithViolation(c, err)
+			return
+		}
+		if _, err := getMembership(context.TODO(), orgID, newUser.ID); err != nil && !checkLimits(c, orgID, plans.LimitMembers) {
+			return
+		}
+
+		if err := addMembership(context.TODO(), orgID, newUser.ID, newUser.Role, invite.InvitedBy); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add user"})
 			return
 		}
+		now := time.Now()
+		_, err = db.GetInviteCollection().UpdateMany(context.TODO(), inviteFilter, bson.M{
+			"$set": bson.M{"status": "accepted", "accepted_at": now},
+		})
+		if err != nil {
+			fmt.Printf("Warning: Failed to mark invite accepted: %v\n", err)
+		}
 		oldRoles := enforcer.GetRolesForUserInDomain(newUser.ID, orgID)
 		for _, role := range oldRoles {
-			_, _ = enforcer.DeleteRoleForUserInDomain(newUser.ID, role, orgID)
+			if !isTeamSubject(role) && !isProjectRole(role) {
+				_, _ = enforcer.DeleteRoleForUserInDomain(newUser.ID, role, orgID)
+			}
 		}
-		ok, err := enforcer.AddGroupingPolicy(newUser.ID, "reader", orgID)
+		ok, err := enforcer.AddGroupingPolicy(newUser.ID, newUser.Role, orgID)
 		if err != nil || !ok {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign role"})
 			return
 		}
 
+		// Invites from a bulk import may also put the user in a team.
+		if invite.TeamID != "" {
+			var team models.Team
+			teamID, _ := primitive.ObjectIDFromHex(invite.TeamID)
+			err := db.GetTeamCollection().FindOne(context.TODO(), bson.M{"_id": teamID, "org_id": orgID}).Decode(&team)
+			if err == nil {
+				err = joinTeam(context.TODO(), enforcer, team, newUser.ID, invite.InvitedBy)
+			}
+			if err != nil {
+				fmt.Printf("Warning: Failed to add %s to team %s: %v\n", newUser.ID, invite.TeamID, err)
+			}
+		}
+
 		err = utils.TriggerInviteAcceptedNotification(newUser.Email, orgID, orgDoc.Name)
 		if err != nil {
 			fmt.Printf("Warning: Failed to send Novu accepted notification: %v\n", err)
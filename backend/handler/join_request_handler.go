@@ -0,0 +1,411 @@
+package handler
+
+import (
+	"backend/db"
+	"backend/models"
+	"backend/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxJoinMessageLength = 500
+	// A user may send this many join requests per joinRequestWindow, across
+	// all orgs.
+	maxJoinRequests   = 5
+	joinRequestWindow = 24 * time.Hour
+	// After a denial the same org can't be asked again for this long.
+	joinRequestCooldown = 7 * 24 * time.Hour
+)
+
+// joinRequestRetryAfter returns how long userID must wait before requesting
+// to join orgID, or zero if they may ask now.
+func joinRequestRetryAfter(ctx context.Context, orgID, userID string) (time.Duration, error) {
+	now := time.Now()
+
+	var denied models.JoinRequest
+	err := db.GetJoinRequestCollection().FindOne(ctx,
+		bson.M{"org_id": orgID, "user_id": userID, "status": "denied", "reviewed_at": bson.M{"$gt": now.Add(-joinRequestCooldown)}},
+		options.FindOne().SetSort(bson.D{{Key: "reviewed_at", Value: -1}}),
+	).Decode(&denied)
+	if err == nil {
+		return denied.ReviewedAt.Add(joinRequestCooldown).Sub(now), nil
+	} else if err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+
+	cursor, err := db.GetJoinRequestCollection().Find(ctx,
+		bson.M{"user_id": userID, "created_at": bson.M{"$gt": now.Add(-joinRequestWindow)}},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(maxJoinRequests),
+	)
+	if err != nil {
+		return 0, err
+	}
+	var recent []models.JoinRequest
+	if err := cursor.All(ctx, &recent); err != nil {
+		return 0, err
+	}
+	if len(recent) < maxJoinRequests {
+		return 0, nil
+	}
+	// The oldest request in the window has to age out first.
+	return recent[len(recent)-1].CreatedAt.Add(joinRequestWindow).Sub(now), nil
+}
+
+func notifyJoinRequestReviewed(request models.JoinRequest) {
+	err := utils.TriggerNotification(request.Email, "org-join-request-reviewed", map[string]interface{}{
+		"orgId":    request.OrgID,
+		"orgName":  request.OrgName,
+		"approved": request.Status == "approved",
+		"role":     request.Role,
+		"note":     request.ReviewNote,
+	})
+	if err != nil {
+		fmt.Printf("Warning: Failed to notify %s about join request: %v\n", request.Email, err)
+	}
+}
+
+// RequestToJoinHandler lets a user ask to join an org they can discover. The
+// org's admins are notified and review the request from their pending queue.
+func RequestToJoinHandler(c *gin.Context) {
+	var input struct {
+		OrgID   string `json:"org_id" binding:"required"`
+		Message string `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+		return
+	}
+	message := strings.TrimSpace(input.Message)
+	if len(message) > maxJoinMessageLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message must be at most " + strconv.Itoa(maxJoinMessageLength) + " characters"})
+		return
+	}
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	identity := user.(models.Identity)
+
+	orgID, err := db.ResolveOrgID(context.TODO(), input.OrgID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+	objID, _ := primitive.ObjectIDFromHex(orgID)
+	var org models.Organization
+	if err := db.GetOrgCollection().FindOne(context.TODO(), bson.M{"_id": objID}).Decode(&org); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+	if _, err := getMembership(context.TODO(), orgID, identity.ID); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": errAlreadyMember.Error()})
+		return
+	}
+	// Private orgs are invite-only and don't admit to existing.
+	if !orgDiscoverable(org, true) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	wait, err := joinRequestRetryAfter(context.TODO(), orgID, identity.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many join requests, try again later"})
+		return
+	}
+
+	request := models.JoinRequest{
+		OrgID:     orgID,
+		OrgName:   org.Name,
+		UserID:    identity.ID,
+		Email:     identity.Traits.Email,
+		Name:      identity.Traits.Name,
+		Message:   message,
+		Status:    "pending",
+		CreatedAt: time.Now(),
+	}
+	res, err := db.GetJoinRequestCollection().InsertOne(context.TODO(), request)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "You already have a pending request for this organization"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create join request"})
+		}
+		return
+	}
+	request.ID = res.InsertedID.(primitive.ObjectID)
+
+	_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+		Action:   "org.join_requested",
+		ActorID:  identity.ID,
+		TargetID: request.ID.Hex(),
+		Domain:   orgID,
+	})
+	notifyOrgAdmins(org, "", "org-join-request", map[string]interface{}{
+		"orgId":     orgID,
+		"orgName":   org.Name,
+		"requestId": request.ID.Hex(),
+		"userEmail": request.Email,
+		"userName":  request.Name,
+		"message":   request.Message,
+	})
+	c.JSON(http.StatusCreated, request)
+}
+
+// GetMyJoinRequestsHandler lists the current user's join requests.
+func GetMyJoinRequestsHandler(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	cursor, err := db.GetJoinRequestCollection().Find(context.TODO(),
+		bson.M{"user_id": user.(models.Identity).ID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(100),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve join requests"})
+		return
+	}
+	requests := []models.JoinRequest{}
+	if err := cursor.All(context.TODO(), &requests); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode join requests"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": requests})
+}
+
+func CancelJoinRequestHandler(c *gin.Context) {
+	var input struct {
+		RequestID string `json:"request_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+		return
+	}
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	objID, err := primitive.ObjectIDFromHex(input.RequestID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+	res, err := db.GetJoinRequestCollection().UpdateOne(context.TODO(),
+		bson.M{"_id": objID, "user_id": user.(models.Identity).ID, "status": "pending"},
+		bson.M{"$set": bson.M{"status": "cancelled"}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel join request"})
+		return
+	}
+	if res.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pending join request not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Join request cancelled"})
+}
+
+// GetJoinRequestsHandler is the admins' queue. It shows pending requests
+// unless ?status= asks for another state.
+func GetJoinRequestsHandler(c *gin.Context) {
+	status := c.DefaultQuery("status", "pending")
+	if !slices.Contains([]string{"pending", "approved", "denied", "cancelled"}, status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+	cursor, err := db.GetJoinRequestCollection().Find(context.TODO(),
+		bson.M{"org_id": c.Param("id"), "status": status},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(200),
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve join requests"})
+		return
+	}
+	requests := []models.JoinRequest{}
+	if err := cursor.All(context.TODO(), &requests); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode join requests"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": requests})
+}
+
+func findPendingJoinRequest(c *gin.Context, requestID string) (models.JoinRequest, bool) {
+	var request models.JoinRequest
+	objID, err := primitive.ObjectIDFromHex(requestID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return request, false
+	}
+	err = db.GetJoinRequestCollection().FindOne(context.TODO(),
+		bson.M{"_id": objID, "org_id": c.Param("id"), "status": "pending"}).Decode(&request)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pending join request not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return request, false
+	}
+	return request, true
+}
+
+// reviewJoinRequest closes a pending request. It reports false if someone
+// else reviewed or cancelled it first.
+func reviewJoinRequest(ctx context.Context, request *models.JoinRequest, status, role, reviewer, note string) (bool, error) {
+	now := time.Now()
+	res, err := db.GetJoinRequestCollection().UpdateOne(ctx,
+		bson.M{"_id": request.ID, "status": "pending"},
+		bson.M{"$set": bson.M{
+			"status":      status,
+			"role":        role,
+			"reviewed_by": reviewer,
+			"review_note": note,
+			"reviewed_at": now,
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+	request.Status, request.Role, request.ReviewedBy, request.ReviewNote, request.ReviewedAt = status, role, reviewer, note, &now
+	return res.ModifiedCount > 0, nil
+}
+
+// ApproveJoinRequestHandler admits the requester with the chosen role,
+// through the same membership and Casbin path as accepted invites.
+func ApproveJoinRequestHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			RequestID string `json:"request_id" binding:"required"`
+			Role      string `json:"role"`
+			Note      string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+			return
+		}
+		if input.Role == "" {
+			input.Role = "reader"
+		}
+		if !slices.Contains(teamRoles, input.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be one of admin, writer or reader"})
+			return
+		}
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		actorID := user.(models.Identity).ID
+		request, ok := findPendingJoinRequest(c, input.RequestID)
+		if !ok {
+			return
+		}
+
+		// The request is claimed before the user is admitted, so one that was
+		// cancelled or denied in the meantime can't be approved.
+		updated, err := reviewJoinRequest(context.TODO(), &request, "approved", input.Role, actorID, strings.TrimSpace(input.Note))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update join request"})
+			return
+		}
+		if !updated {
+			c.JSON(http.StatusConflict, gin.H{"error": "Join request is no longer pending"})
+			return
+		}
+		if err := grantMembership(context.TODO(), enforcer, request.OrgID, request.UserID, input.Role, actorID); err != nil {
+			_, revertErr := db.GetJoinRequestCollection().UpdateOne(context.TODO(),
+				bson.M{"_id": request.ID, "status": "approved"},
+				bson.M{
+					"$set":   bson.M{"status": "pending"},
+					"$unset": bson.M{"role": "", "reviewed_by": "", "review_note": "", "reviewed_at": ""},
+				},
+			)
+			if revertErr != nil {
+				fmt.Printf("Warning: Failed to reopen join request %s: %v\n", request.ID.Hex(), revertErr)
+			}
+			respondGrantError(c, err, "Failed to add user")
+			return
+		}
+		// Any invite still pending for the user is now moot, and its rule
+		// must go so accepting it can't change the approved role.
+		_, _ = db.GetInviteCollection().UpdateMany(context.TODO(),
+			bson.M{"org_id": request.OrgID, "user_id": request.UserID, "status": "pending"},
+			bson.M{"$set": bson.M{"status": "accepted", "accepted_at": time.Now()}},
+		)
+		if _, err := enforcer.DeleteRoleForUserInDomain(request.UserID, "invite", request.OrgID); err != nil {
+			fmt.Printf("Warning: Failed to remove invite rule for %s: %v\n", request.UserID, err)
+		}
+
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.join_request_approved",
+			ActorID:  actorID,
+			TargetID: request.UserID,
+			Domain:   request.OrgID,
+			Details:  map[string]interface{}{"request_id": request.ID.Hex(), "role": input.Role},
+		})
+		notifyJoinRequestReviewed(request)
+		c.JSON(http.StatusOK, gin.H{"message": "User added to organization", "request": request})
+	}
+}
+
+func DenyJoinRequestHandler(c *gin.Context) {
+	var input struct {
+		RequestID string `json:"request_id" binding:"required"`
+		Note      string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+		return
+	}
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	actorID := user.(models.Identity).ID
+	request, ok := findPendingJoinRequest(c, input.RequestID)
+	if !ok {
+		return
+	}
+
+	updated, err := reviewJoinRequest(context.TODO(), &request, "denied", "", actorID, strings.TrimSpace(input.Note))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deny join request"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusConflict, gin.H{"error": "Join request was already handled"})
+		return
+	}
+
+	_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+		Action:   "org.join_request_denied",
+		ActorID:  actorID,
+		TargetID: request.UserID,
+		Domain:   request.OrgID,
+		Details:  map[string]interface{}{"request_id": request.ID.Hex()},
+	})
+	notifyJoinRequestReviewed(request)
+	c.JSON(http.StatusOK, gin.H{"message": "Join request denied", "request": request})
+}
@@ -0,0 +1,318 @@
+package handler
+
+import (
+	"backend/db"
+	"backend/models"
+	"backend/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	errOrgNotFound    = errors.New("Organization not found")
+	errMemberNotFound = errors.New("User is not a member of this organization")
+	errSoleAdmin      = errors.New("The only admin of an organization cannot leave or be removed")
+	errOwner          = errors.New("The organization owner cannot leave or be removed; transfer ownership first")
+	errLastAdmin      = errors.New("An organization must keep at least one admin")
+	errOwnerRole      = errors.New("The organization owner must stay an admin; transfer ownership first")
+	errAdminsBusy     = errors.New("Another change to the organization's admins is in progress, please retry")
+)
+
+// adminLockTTL bounds how long a crashed request can hold an org's admin lock.
+const adminLockTTL = 30 * time.Second
+
+// lockOrgAdmins takes the org's admin lock, waiting briefly if another
+// request holds it. The returned func releases it.
+func lockOrgAdmins(ctx context.Context, orgID primitive.ObjectID) (func(), error) {
+	for attempt := 0; attempt < 20; attempt++ {
+		now := time.Now().Truncate(time.Millisecond) // as stored by Mongo
+		res, err := db.GetOrgCollection().UpdateOne(ctx,
+			bson.M{"_id": orgID, "$or": bson.A{
+				bson.M{"admin_lock": bson.M{"$exists": false}},
+				bson.M{"admin_lock": bson.M{"$lt": now.Add(-adminLockTTL)}},
+			}},
+			bson.M{"$set": bson.M{"admin_lock": now}},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if res.ModifiedCount == 1 {
+			return func() {
+				_, err := db.GetOrgCollection().UpdateOne(context.TODO(),
+					bson.M{"_id": orgID, "admin_lock": now},
+					bson.M{"$unset": bson.M{"admin_lock": ""}},
+				)
+				if err != nil {
+					fmt.Printf("Warning: Failed to release admin lock for org %s: %v\n", orgID.Hex(), err)
+				}
+			}, nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return nil, errAdminsBusy
+}
+
+// adminChange describes a pending change for orgAdminIDs to leave out, so
+// the result is who would still be admin after it.
+type adminChange struct {
+	demoted    string // a user losing their direct admin role
+	team       string // an admin team losing its role, or with teamMember,
+	teamMember string // a member leaving it
+}
+
+// orgAdminIDs returns the users who are admins of the org, directly or
+// through an admin team.
+func orgAdminIDs(ctx context.Context, orgID string, change adminChange) ([]string, error) {
+	direct := bson.M{"org_id": orgID, "role": "admin"}
+	if change.demoted != "" {
+		direct["user_id"] = bson.M{"$ne": change.demoted}
+	}
+	raw, err := db.GetMembershipCollection().Distinct(ctx, "user_id", direct)
+	if err != nil {
+		return nil, err
+	}
+	teamIDs, err := db.GetTeamCollection().Distinct(ctx, "_id", bson.M{"org_id": orgID, "role": "admin"})
+	if err != nil {
+		return nil, err
+	}
+	hexIDs := make(bson.A, 0, len(teamIDs))
+	for _, id := range teamIDs {
+		if hex := id.(primitive.ObjectID).Hex(); hex != change.team || change.teamMember != "" {
+			hexIDs = append(hexIDs, hex)
+		}
+	}
+	if len(hexIDs) > 0 {
+		filter := bson.M{"team_id": bson.M{"$in": hexIDs}}
+		if change.teamMember != "" {
+			filter["$nor"] = bson.A{bson.M{"team_id": change.team, "user_id": change.teamMember}}
+		}
+		viaTeams, err := db.GetTeamMemberCollection().Distinct(ctx, "user_id", filter)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, viaTeams...)
+	}
+
+	ids := make([]string, 0, len(raw))
+	for _, id := range raw {
+		if !slices.Contains(ids, id.(string)) {
+			ids = append(ids, id.(string))
+		}
+	}
+	return ids, nil
+}
+
+// guardAdmins takes the org's admin lock and makes sure the org keeps an
+// admin after change. The caller makes the change, then calls the returned
+// func to release the lock.
+func guardAdmins(ctx context.Context, orgID string, change adminChange) (func(), error) {
+	objID, err := primitive.ObjectIDFromHex(orgID)
+	if err != nil {
+		return nil, errOrgNotFound
+	}
+	unlock, err := lockOrgAdmins(ctx, objID)
+	if err != nil {
+		return nil, err
+	}
+	admins, err := orgAdminIDs(ctx, orgID, change)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	if len(admins) == 0 {
+		unlock()
+		return nil, errLastAdmin
+	}
+	return unlock, nil
+}
+
+func respondAdminGuardError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, errOrgNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errLastAdmin), errors.Is(err, errOwnerRole), errors.Is(err, errAdminsBusy):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// removeMember deletes userID's membership and every grouping rule they hold
+// in the org domain. It refuses to remove the owner or the last admin,
+// counting admins through teams as well.
+func removeMember(enforcer *casbin.Enforcer, orgID, userID string) (models.Organization, models.Member, error) {
+	var org models.Organization
+	var member models.Member
+
+	objID, err := primitive.ObjectIDFromHex(orgID)
+	if err != nil {
+		return org, member, errOrgNotFound
+	}
+	if err := db.GetOrgCollection().FindOne(context.TODO(), bson.M{"_id": objID}).Decode(&org); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return org, member, errOrgNotFound
+		}
+		return org, member, err
+	}
+
+	membership, err := getMembership(context.TODO(), orgID, userID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return org, member, errMemberNotFound
+		}
+		return org, member, err
+	}
+	if org.CreatedBy == userID {
+		return org, member, errOwner
+	}
+
+	// The admin check and the removal must not interleave with another
+	// removal, or two admins leaving together could both pass it.
+	unlock, err := lockOrgAdmins(context.TODO(), objID)
+	if err != nil {
+		return org, member, err
+	}
+	defer unlock()
+
+	admins, err := orgAdminIDs(context.TODO(), orgID, adminChange{})
+	if err != nil {
+		return org, member, err
+	}
+	if slices.Contains(admins, userID) && len(admins) <= 1 {
+		return org, member, errSoleAdmin
+	}
+
+	if _, err := db.GetMembershipCollection().DeleteOne(context.TODO(), bson.M{"_id": membership.ID}); err != nil {
+		return org, member, err
+	}
+	if err := removeFromTeams(context.TODO(), orgID, userID); err != nil {
+		return org, member, err
+	}
+	if err := removeFromProjects(context.TODO(), orgID, userID); err != nil {
+		return org, member, err
+	}
+	// This also drops their links to teams and project roles in the org.
+	if _, err := enforcer.RemoveFilteredGroupingPolicy(0, userID, "", orgID); err != nil {
+		return org, member, err
+	}
+
+	return org, resolveMember(membership), nil
+}
+
+func respondRemoveMemberError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errOrgNotFound), errors.Is(err, errMemberNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errSoleAdmin), errors.Is(err, errOwner), errors.Is(err, errAdminsBusy):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+	}
+}
+
+func notifyOrgAdmins(org models.Organization, skip, name string, payload map[string]interface{}) {
+	admins, err := orgMembers(context.TODO(), org.ID.Hex(), "admin")
+	if err != nil {
+		fmt.Printf("Warning: Failed to load org admins: %v\n", err)
+		return
+	}
+	for _, u := range admins {
+		if u.ID == skip {
+			continue
+		}
+		if err := utils.TriggerNotification(u.Email, name, payload); err != nil {
+			fmt.Printf("Warning: Failed to notify %s: %v\n", u.Email, err)
+		}
+	}
+}
+
+func RemoveMemberHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			UserID string `json:"user_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+			return
+		}
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		orgID := c.Param("id")
+
+		org, member, err := removeMember(enforcer, orgID, input.UserID)
+		if err != nil {
+			respondRemoveMemberError(c, err)
+			return
+		}
+
+		actorID := user.(models.Identity).ID
+		if err := markRemoved(context.TODO(), orgID, input.UserID, actorID); err != nil {
+			fmt.Printf("Warning: Failed to record removal of %s from org %s: %v\n", input.UserID, orgID, err)
+		}
+		err = utils.TriggerNotification(member.Email, "org-member-removed", map[string]interface{}{
+			"orgId":   orgID,
+			"orgName": org.Name,
+		})
+		if err != nil {
+			fmt.Printf("Warning: Failed to notify removed member: %v\n", err)
+		}
+		notifyOrgAdmins(org, actorID, "org-member-removed-admin", map[string]interface{}{
+			"orgId":       orgID,
+			"orgName":     org.Name,
+			"memberEmail": member.Email,
+		})
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.member_removed",
+			ActorID:  actorID,
+			TargetID: member.ID,
+			Domain:   orgID,
+		})
+
+		c.JSON(http.StatusOK, gin.H{"message": "Member removed from organization"})
+	}
+}
+
+func LeaveOrgHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		orgID := c.Param("id")
+		userID := user.(models.Identity).ID
+
+		org, member, err := removeMember(enforcer, orgID, userID)
+		if err != nil {
+			respondRemoveMemberError(c, err)
+			return
+		}
+
+		notifyOrgAdmins(org, userID, "org-member-left", map[string]interface{}{
+			"orgId":       orgID,
+			"orgName":     org.Name,
+			"memberEmail": member.Email,
+		})
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.member_left",
+			ActorID:  userID,
+			TargetID: userID,
+			Domain:   orgID,
+		})
+
+		c.JSON(http.StatusOK, gin.H{"message": "You have left the organization"})
+	}
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"backend/db"
+	"backend/models"
+	"backend/plans"
+	"backend/policy"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	errAlreadyMember = errors.New("User is already a member of this organization")
+	errOrgArchived   = errors.New("Organization is archived")
+)
+
+// orgArchived reports whether orgID has been soft-deleted.
+func orgArchived(ctx context.Context, orgID string) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(orgID)
+	if err != nil {
+		return false, err
+	}
+	count, err := db.GetOrgCollection().CountDocuments(ctx, bson.M{"_id": objID, "archived_at": bson.M{"$exists": true}})
+	return count > 0, err
+}
+
+func getMembership(ctx context.Context, orgID, userID string) (models.Membership, error) {
+	var membership models.Membership
+	err := db.GetMembershipCollection().FindOne(ctx, bson.M{"org_id": orgID, "user_id": userID}).Decode(&membership)
+	return membership, err
+}
+
+// addMembership records userID as a member of orgID. Adding an existing
+// member again leaves their current membership untouched.
+func addMembership(ctx context.Context, orgID, userID, role, invitedBy string) error {
+	_, err := db.GetMembershipCollection().UpdateOne(ctx,
+		bson.M{"org_id": orgID, "user_id": userID},
+		bson.M{"$setOnInsert": models.Membership{
+			OrgID:     orgID,
+			UserID:    userID,
+			Role:      role,
+			JoinedAt:  time.Now(),
+			InvitedBy: invitedBy,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// grantMembership adds userID to orgID with role outside of the invite flow,
+// recording the membership and the matching grouping rule. Separation of
+// duties is checked first.
+func grantMembership(ctx context.Context, enforcer *casbin.Enforcer, orgID, userID, role, invitedBy string) error {
+	if _, err := getMembership(ctx, orgID, userID); err == nil {
+		return errAlreadyMember
+	} else if err != mongo.ErrNoDocuments {
+		return err
+	}
+	if archived, err := orgArchived(ctx, orgID); err != nil {
+		return err
+	} else if archived {
+		return errOrgArchived
+	}
+	if err := plans.Check(ctx, orgID, plans.LimitMembers); err != nil {
+		return err
+	}
+	if err := policy.CheckSeparation(enforcer, userID, role, orgID, false); err != nil {
+		return err
+	}
+	if err := addMembership(ctx, orgID, userID, role, invitedBy); err != nil {
+		return err
+	}
+	if err := plans.Recheck(ctx, orgID, plans.LimitMembers); err != nil {
+		_, _ = db.GetMembershipCollection().DeleteOne(ctx, bson.M{"org_id": orgID, "user_id": userID})
+		return err
+	}
+	if !strings.HasPrefix(invitedBy, "domain:") {
+		// Being let back in by an admin lifts a removal.
+		_, err := db.GetAutoJoinCollection().UpdateOne(ctx,
+			bson.M{"org_id": orgID, "user_id": userID},
+			bson.M{"$unset": bson.M{"removed_at": "", "removed_by": ""}},
+		)
+		if err != nil {
+			fmt.Printf("Warning: Failed to clear removal of %s from org %s: %v\n", userID, orgID, err)
+		}
+	}
+	_, err := enforcer.AddRoleForUserInDomain(userID, role, orgID)
+	return err
+}
+
+// respondGrantError writes the response for a grantMembership error, using
+// fallback for unexpected ones.
+func respondGrantError(c *gin.Context, err error, fallback string) {
+	var violation *policy.Violation
+	switch {
+	case errors.Is(err, errAlreadyMember), errors.Is(err, errOrgArchived):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &violation):
+		abortWithViolation(c, err)
+	case respondLimitError(c, err):
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+func findMemberships(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.Membership, error) {
+	cursor, err := db.GetMembershipCollection().Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	memberships := []models.Membership{}
+	if err := cursor.All(ctx, &memberships); err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// identityDirectory indexes the Kratos identities by ID so memberships can be
+// shown with names and emails.
+func identityDirectory() (map[string]models.Identity, error) {
+	identities, err := fetchIdentities()
+	if err != nil {
+		return nil, err
+	}
+	dir := make(map[string]models.Identity, len(identities))
+	for _, identity := range identities {
+		dir[identity.ID] = identity
+	}
+	return dir, nil
+}
+
+func toMembers(memberships []models.Membership, dir map[string]models.Identity) []models.Member {
+	members := make([]models.Member, 0, len(memberships))
+	for _, m := range memberships {
+		identity := dir[m.UserID]
+		members = append(members, models.Member{
+			ID:        m.UserID,
+			Email:     identity.Traits.Email,
+			Name:      identity.Traits.Name,
+			Role:      m.Role,
+			JoinedAt:  m.JoinedAt,
+			InvitedBy: m.InvitedBy,
+		})
+	}
+	return members
+}
+
+// resolveMember fills in a single member's traits from Kratos. A failed
+// lookup leaves them blank.
+func resolveMember(membership models.Membership) models.Member {
+	member := toMembers([]models.Membership{membership}, map[string]models.Identity{})[0]
+	if identity, err := fetchIdentity(membership.UserID); err == nil {
+		member.Email = identity.Traits.Email
+		member.Name = identity.Traits.Name
+	}
+	return member
+}
+
+// orgMembers lists the members of an org, optionally only those with role,
+// resolved against Kratos.
+func orgMembers(ctx context.Context, orgID, role string) ([]models.Member, error) {
+	filter := bson.M{"org_id": orgID}
+	if role != "" {
+		filter["role"] = role
+	}
+	memberships, err := findMemberships(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := identityDirectory()
+	if err != nil {
+		return nil, err
+	}
+	return toMembers(memberships, dir), nil
+}
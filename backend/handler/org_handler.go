@@ -3,8 +3,19 @@ package handler
 import (
 	"backend/db"
 	"backend/models"
+	"backend/orgsettings"
+	"backend/plans"
+	"backend/policy"
+	"backend/temporal/activities"
+	"backend/temporal/workflows"
+	"backend/utils"
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/casbin/casbin/v2"
@@ -12,13 +23,167 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
 )
 
+// orgPolicies lists the rules every organization domain gets. New org routes
+// are added here; EnsureOrgPolicies backfills them for existing orgs.
+func orgPolicies(orgID string) [][]string {
+	return [][]string{
+		{"reader", orgID, "/orgs/get/" + orgID, "GET"},
+		{"reader", orgID, "/orgs/members/" + orgID, "GET"},
+		{"writer", orgID, "/orgs/invite/" + orgID, "POST"},
+		{"invite", orgID, "/orgs/accept/" + orgID, "GET"},
+		{"admin", orgID, "/orgs/update-role/" + orgID, "POST"},
+		{"admin", orgID, "/orgs/update/" + orgID, "PUT"},
+		{"admin", orgID, "/orgs/delete/" + orgID, "DELETE"},
+		{"admin", orgID, "/orgs/remove-member/" + orgID, "POST"},
+		{"reader", orgID, "/orgs/leave/" + orgID, "POST"},
+		{"admin", orgID, "/orgs/transfer-ownership/" + orgID, "POST"},
+		{"reader", orgID, "/orgs/accept-ownership/" + orgID, "POST"},
+		{"reader", orgID, "/orgs/decline-ownership/" + orgID, "POST"},
+		{"admin", orgID, "/orgs/audit/" + orgID, "GET"},
+		{"reader", orgID, "/orgs/teams/" + orgID, "GET"},
+		{"reader", orgID, "/orgs/teams/members/" + orgID, "GET"},
+		{"admin", orgID, "/orgs/teams/create/" + orgID, "POST"},
+		{"admin", orgID, "/orgs/teams/update/" + orgID, "PUT"},
+		{"admin", orgID, "/orgs/teams/delete/" + orgID, "DELETE"},
+		{"admin", orgID, "/orgs/teams/add-member/" + orgID, "POST"},
+		{"admin", orgID, "/orgs/teams/remove-member/" + orgID, "POST"},
+		// Project routes only need org membership; the handlers check the
+		// project's own roles.
+		{"reader", orgID, "/orgs/projects/" + orgID, "GET"},
+		{"reader", orgID, "/orgs/projects/get/" + orgID, "GET"},
+		{"writer", orgID, "/orgs/projects/create/" + orgID, "POST"},
+		{"reader", orgID, "/orgs/projects/update/" + orgID, "PUT"},
+		{"reader", orgID, "/orgs/projects/delete/" + orgID, "DELETE"},
+		{"reader", orgID, "/orgs/projects/members/" + orgID, "GET"},
+		{"reader", orgID, "/orgs/projects/add-member/" + orgID, "POST"},
+		{"reader", orgID, "/orgs/projects/remove-member/" + orgID, "POST"},
+		{"admin", orgID, "/orgs/members/inactive/" + orgID, "GET"},
+		{"admin", orgID, "/orgs/export/" + orgID, "GET"},
+		{"reader", orgID, "/orgs/settings/" + orgID, "GET"},
+		{"admin", orgID, "/orgs/settings/" + orgID, "PUT"},
+		{"admin", orgID, "/orgs/settings/logo/" + orgID, "POST"},
+		{"admin", orgID, "/orgs/settings/logo/" + orgID, "DELETE"},
+		{"admin", orgID, "/orgs/join-requests/" + orgID, "GET"},
+		{"admin", orgID, "/orgs/join-requests/approve/" + orgID, "POST"},
+		{"admin", orgID, "/orgs/join-requests/deny/" + orgID, "POST"},
+		// Reading a secret is further limited to the secret's read roles.
+		{"writer", orgID, "/orgs/secrets/" + orgID, "GET"},
+		{"writer", orgID, "/orgs/secrets/versions/" + orgID, "GET"},
+		{"reader", orgID, "/orgs/secrets/read/" + orgID, "GET"},
+		{"admin", orgID, "/orgs/secrets/put/" + orgID, "POST"},
+		{"admin", orgID, "/orgs/secrets/delete/" + orgID, "DELETE"},
+		{"admin", orgID, "/orgs/domains/" + orgID, "GET"},
+		{"admin", orgID, "/orgs/domains/claim/" + orgID, "POST"},
+		{"admin", orgID, "/orgs/domains/verify/" + orgID, "POST"},
+		{"admin", orgID, "/orgs/domains/delete/" + orgID, "DELETE"},
+		{"reader", orgID, "/orgs/usage/" + orgID, "GET"},
+		{"admin", orgID, "/orgs/restore/" + orgID, "POST"},
+		{"admin", orgID, "/orgs/import/" + orgID, "POST"},
+		{"admin", orgID, "/orgs/import/status/" + orgID, "GET"},
+		{"admin", orgID, "/orgs/import/errors/" + orgID, "GET"},
+	}
+}
+
+func EnsureOrgPolicies(enforcer *casbin.Enforcer) error {
+	cursor, err := db.GetOrgCollection().Find(context.TODO(), bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(context.TODO())
+
+	var orgs []models.Organization
+	if err := cursor.All(context.TODO(), &orgs); err != nil {
+		return err
+	}
+
+	_ = enforcer.LoadPolicy()
+	var missing [][]string
+	for _, org := range orgs {
+		for _, rule := range orgPolicies(org.ID.Hex()) {
+			// Archived orgs keep their domain frozen until restored; only
+			// the routes that stay open while archived are put back.
+			if org.ArchivedAt != nil && activities.IsWriteRule(rule, org.ID.Hex()) {
+				continue
+			}
+			if ok, _ := enforcer.HasPolicy(rule); !ok {
+				missing = append(missing, rule)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		if _, err := enforcer.AddPolicies(missing); err != nil {
+			return err
+		}
+	}
+	return ensureProjectPolicies(enforcer)
+}
+
+// ensureProjectPolicies gives projects created before a change to
+// projectPolicies the rules they are missing.
+func ensureProjectPolicies(enforcer *casbin.Enforcer) error {
+	projects, err := findAll[models.Project](context.TODO(), db.GetProjectCollection(), bson.M{})
+	if err != nil {
+		return err
+	}
+	var missingP, missingG [][]string
+	for _, project := range projects {
+		policies, grouping := projectPolicies(project.OrgID, project.ID.Hex())
+		for _, rule := range policies {
+			if ok, _ := enforcer.HasPolicy(rule); !ok {
+				missingP = append(missingP, rule)
+			}
+		}
+		for _, rule := range grouping {
+			if ok, _ := enforcer.HasGroupingPolicy(rule); !ok {
+				missingG = append(missingG, rule)
+			}
+		}
+	}
+	if len(missingP) > 0 {
+		if _, err := enforcer.AddPolicies(missingP); err != nil {
+			return err
+		}
+	}
+	if len(missingG) > 0 {
+		if _, err := enforcer.AddNamedGroupingPolicies("g", missingG); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// initOrgDomain sets up a new org's Casbin domain: the org routes, the role
+// hierarchy and ownerID as admin.
+func initOrgDomain(enforcer *casbin.Enforcer, orgID, ownerID string) error {
+	ok, err := enforcer.AddPolicies(orgPolicies(orgID))
+	if err == nil && !ok {
+		err = errors.New("org policies already exist")
+	}
+	if err != nil {
+		return err
+	}
+	grouingPolicies := [][]string{
+		{"admin", "writer", orgID},
+		{"writer", "reader", orgID},
+	}
+	if _, err := enforcer.AddNamedGroupingPolicies("g", grouingPolicies); err != nil {
+		return err
+	}
+	_, err = policy.AddGroupingPolicy(enforcer, ownerID, "admin", orgID)
+	return err
+}
+
 func CreateOrganizationHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input struct {
 			Name        string `json:"name" binding:"required"`
 			Description string `json:"description" binding:"required"`
+			Visibility  string `json:"visibility"`
 		}
 
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -31,28 +196,59 @@ func CreateOrganizationHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
 			return
 		}
 
-		admin := models.User{
-			ID:    user.(models.Identity).ID,
-			Email: user.(models.Identity).Traits.Email,
-			Name:  user.(models.Identity).Traits.Name,
-			Role:  "admin"}
-
 		userID := user.(models.Identity).ID
 		if userID == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
 
+		name := strings.TrimSpace(input.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Organization name cannot be empty"})
+			return
+		}
+		if input.Visibility == "" {
+			input.Visibility = visibilityPrivate
+		}
+		if !slices.Contains(orgVisibilities, input.Visibility) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Visibility must be private, internal or public"})
+			return
+		}
+		taken, err := db.OrgNameTaken(context.TODO(), name, primitive.NilObjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": "An organization with this name already exists"})
+			return
+		}
+		slug, err := db.UniqueOrgSlug(context.TODO(), name, primitive.NilObjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+			return
+		}
+
 		org := models.Organization{
-			Name:        input.Name,
+			Name:        name,
+			Slug:        slug,
 			Description: input.Description,
 			CreatedBy:   userID,
 			CreatedAt:   time.Now(),
-			Users:       []models.User{admin},
+			Plan:        plans.Default,
+			Visibility:  input.Visibility,
 		}
 
 		collection := db.GetOrgCollection()
 		res, err := collection.InsertOne(context.TODO(), org)
+		if db.IsDuplicateOrgName(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "An organization with this name already exists"})
+			return
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Organization slug already in use, please retry"})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
 			return
@@ -60,32 +256,15 @@ func CreateOrganizationHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
 
 		orgID := res.InsertedID.(primitive.ObjectID).Hex()
 
-		policies := [][]string{
-			{"reader", orgID, "/orgs/get/" + orgID, "GET"},
-			{"writer", orgID, "/orgs/invite/" + orgID, "POST"},
-			{"invite", orgID, "/orgs/accept/" + orgID, "GET"},
-			{"admin", orgID, "/orgs/update-role/" + orgID, "POST"},
+		if err := addMembership(context.TODO(), orgID, userID, "admin", ""); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add organization admin"})
+			return
 		}
 
-		ok, err := enforcer.AddPolicies(policies)
-		if err != nil || !ok {
+		if err := initOrgDomain(enforcer, orgID, userID); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign policies"})
 			return
 		}
-		grouingPolicies := [][]string{
-			{"admin", "writer", orgID},
-			{"writer", "reader", orgID},
-		}
-		ok, err = enforcer.AddNamedGroupingPolicies("g", grouingPolicies)
-		if err != nil || !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign role"})
-			return
-		}
-		ok, err = enforcer.AddGroupingPolicy(userID, "admin", orgID)
-		if err != nil || !ok {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign role"})
-			return
-		}
 		org.ID = res.InsertedID.(primitive.ObjectID)
 
 		c.JSON(http.StatusOK, org)
@@ -105,26 +284,82 @@ func GetAdminOrgs(c *gin.Context) {
 		return
 	}
 
-	collection := db.GetOrgCollection()
-	filter := map[string]interface{}{
-		"created_by": userID,
+	// Archived orgs are only listed on request, so admins can find them to
+	// restore.
+	listOrgs(c, bson.M{
+		"created_by":  userID,
+		"archived_at": bson.M{"$exists": c.Query("archived") == "true"},
+	})
+}
+
+// listOrgs answers an org listing request with one page of the orgs matching
+// base, honouring the limit, cursor, q, search, sort and order parameters.
+func listOrgs(c *gin.Context, base bson.M) {
+	orgs, nextCursor, ok := findOrgPage(c, base)
+	if !ok {
+		return
 	}
-	cursor, err := collection.Find(context.TODO(), filter)
+	c.JSON(http.StatusOK, gin.H{"data": orgs, "next_cursor": nextCursor})
+}
+
+// findOrgPage loads the page of orgs listOrgs answers with. On failure the
+// error response has already been written.
+func findOrgPage(c *gin.Context, base bson.M) ([]models.Organization, string, bool) {
+	params, err := parsePageParams(c, "created_at", "name")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, "", false
+	}
+
+	conds := bson.A{base}
+	if params.Prefix != "" {
+		conds = append(conds, bson.M{"name": prefixPattern(params.Prefix)})
+	}
+	if params.Cursor != nil {
+		lastID, err := primitive.ObjectIDFromHex(params.Cursor.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return nil, "", false
+		}
+		conds = append(conds, params.afterCursor(params.Sort, "_id", lastID))
+	}
+	filter := bson.M{"$and": conds}
+	if params.Search != "" {
+		filter["$text"] = bson.M{"$search": params.Search}
+	}
+
+	opts := options.Find().
+		SetSort(params.sortSpec(params.Sort, "_id")).
+		SetLimit(params.Limit + 1)
+	cursor, err := db.GetOrgCollection().Find(context.TODO(), filter, opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizations"})
-		return
+		return nil, "", false
 	}
 	defer cursor.Close(context.TODO())
 
-	var orgs []models.Organization
+	orgs := []models.Organization{}
 	if err := cursor.All(context.TODO(), &orgs); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode organizations"})
-		return
+		return nil, "", false
 	}
 
-	c.JSON(http.StatusOK, orgs)
+	nextCursor := ""
+	if int64(len(orgs)) > params.Limit {
+		orgs = orgs[:params.Limit]
+		last := orgs[len(orgs)-1]
+		value := last.Name
+		if params.Sort == "created_at" {
+			value = last.CreatedAt.Format(time.RFC3339Nano)
+		}
+		nextCursor = encodeCursor(value, last.ID.Hex())
+	}
+	return orgs, nextCursor, true
 }
 
+// GetOrgByIDHandler answers members with the full org. Non-members, including
+// anonymous callers, only get the directory view of orgs visible to them;
+// other orgs are reported as not found.
 func GetOrgByIDHandler(c *gin.Context) {
 	orgID := c.Param("id")
 	objectID, err := primitive.ObjectIDFromHex(orgID)
@@ -135,11 +370,7 @@ func GetOrgByIDHandler(c *gin.Context) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	user, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
-		return
-	}
+	user, authenticated := c.Get("user")
 	var org models.Organization
 	orgsCollection := db.GetOrgCollection()
 	err = orgsCollection.FindOne(ctx, bson.M{"_id": objectID}).Decode(&org)
@@ -151,20 +382,139 @@ func GetOrgByIDHandler(c *gin.Context) {
 		}
 		return
 	}
-	role := "reader"
-	for _, val := range org.Users {
-		if val.ID == user.(models.Identity).ID {
-			role = val.Role
-			break
+	// The effective role includes anything granted through teams. A pending
+	// invite alone doesn't make the caller a member.
+	role := effectiveRole(c.GetStringSlice("org_roles"))
+	if !slices.Contains(teamRoles, role) {
+		role = "none"
+	}
+	if role == "none" && authenticated {
+		if membership, err := getMembership(ctx, orgID, user.(models.Identity).ID); err == nil {
+			role = membership.Role
+		}
+	}
+	memberCount, err := db.GetMembershipCollection().CountDocuments(ctx, bson.M{"org_id": orgID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if role == "none" {
+		if !orgDiscoverable(org, authenticated) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
 		}
+		c.JSON(http.StatusOK, gin.H{
+			"org":          directoryOrg(org, memberCount),
+			"role":         role,
+			"user":         user,
+			"member_count": memberCount,
+		})
+		return
 	}
 
+	lastActivity, err := lastOrgActivity(ctx, orgID)
+	if err != nil {
+		fmt.Printf("Warning: Failed to load org activity: %v\n", err)
+	}
+
+	org.Visibility = orgVisibility(org)
+	settings := orgsettings.Public(orgID, org.Settings)
+	org.Settings = &settings
 	c.JSON(http.StatusOK, gin.H{
-		"org":  org,
-		"role": role,
-		"user": user,
+		"org":              org,
+		"role":             role,
+		"user":             user,
+		"member_count":     memberCount,
+		"last_activity_at": lastActivity,
 	})
 }
+
+// GetOrgMembersHandler pages through an org's members. On top of the usual
+// paging parameters it accepts role to filter by org role; q and search are
+// matched against the members' Kratos names and emails.
+func GetOrgMembersHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	if !primitive.IsValidObjectID(orgID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	params, err := parsePageParams(c, "joined_at", "role")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dir, err := identityDirectory()
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	conds := bson.A{bson.M{"org_id": orgID}}
+	if role := c.Query("role"); role != "" {
+		conds = append(conds, bson.M{"role": role})
+	}
+	if params.Prefix != "" || params.Search != "" {
+		prefix := strings.ToLower(params.Prefix)
+		search := strings.ToLower(params.Search)
+		ids := []string{}
+		for id, identity := range dir {
+			name := strings.ToLower(identity.Traits.Name)
+			email := strings.ToLower(identity.Traits.Email)
+			if prefix != "" && !strings.HasPrefix(name, prefix) && !strings.HasPrefix(email, prefix) {
+				continue
+			}
+			if search != "" && !strings.Contains(name, search) && !strings.Contains(email, search) {
+				continue
+			}
+			ids = append(ids, id)
+		}
+		conds = append(conds, bson.M{"user_id": bson.M{"$in": ids}})
+	}
+	if params.Cursor != nil {
+		lastID, err := primitive.ObjectIDFromHex(params.Cursor.ID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		conds = append(conds, params.afterCursor(params.Sort, "_id", lastID))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	opts := options.Find().
+		SetSort(params.sortSpec(params.Sort, "_id")).
+		SetLimit(params.Limit + 1)
+	memberships, err := findMemberships(ctx, bson.M{"$and": conds}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve members"})
+		return
+	}
+
+	nextCursor := ""
+	if int64(len(memberships)) > params.Limit {
+		memberships = memberships[:params.Limit]
+		last := memberships[len(memberships)-1]
+		value := last.Role
+		if params.Sort == "joined_at" {
+			value = last.JoinedAt.Format(time.RFC3339Nano)
+		}
+		nextCursor = encodeCursor(value, last.ID.Hex())
+	}
+
+	members, err := withLastSeen(ctx, orgID, toMembers(memberships, dir))
+	if err != nil {
+		fmt.Printf("Warning: Failed to load member activity: %v\n", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": members, "next_cursor": nextCursor})
+}
+
+// UpdateUserRoleInOrgHandler replaces a member's org role. The role must be
+// a built-in one or a custom role the org already has, so no new custom role
+// can be introduced past the plan's limit. The owner and the last admin
+// cannot be demoted.
 func UpdateUserRoleInOrgHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input struct {
@@ -178,25 +528,69 @@ func UpdateUserRoleInOrgHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
 		}
 
 		orgID := c.Param("id")
-		objectID, err := primitive.ObjectIDFromHex(orgID)
-		if err != nil {
+		if !primitive.IsValidObjectID(orgID) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
 			return
 		}
+		if !slices.Contains(teamRoles, input.Role) {
+			custom, err := plans.CustomRoles(context.TODO(), orgID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load roles"})
+				return
+			}
+			if !slices.Contains(custom, input.Role) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be admin, writer, reader or one of the organization's custom roles"})
+				return
+			}
+		}
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
 
-		filter := bson.M{"_id": objectID, "users._id": input.UserID}
-		update := bson.M{"$set": bson.M{"users.$.role": input.Role}}
+		if err := policy.CheckSelfElevation(enforcer, user.(models.Identity).ID, input.UserID, input.Role, orgID); err != nil {
+			abortWithViolation(c, err)
+			return
+		}
+		if err := policy.CheckSeparation(enforcer, input.UserID, input.Role, orgID, true); err != nil {
+			abortWithViolation(c, err)
+			return
+		}
 
-		orgsCollection := db.GetOrgCollection()
-		result, err := orgsCollection.UpdateOne(context.TODO(), filter, update)
+		if input.Role != "admin" {
+			org, ok := findOrg(c)
+			if !ok {
+				return
+			}
+			if org.CreatedBy == input.UserID {
+				c.JSON(http.StatusConflict, gin.H{"error": errOwnerRole.Error()})
+				return
+			}
+			unlock, err := guardAdmins(context.TODO(), orgID, adminChange{demoted: input.UserID})
+			if err != nil {
+				respondAdminGuardError(c, err, "Failed to update user role")
+				return
+			}
+			defer unlock()
+		}
+
+		filter := bson.M{"org_id": orgID, "user_id": input.UserID}
+		update := bson.M{"$set": bson.M{"role": input.Role}}
+
+		result, err := db.GetMembershipCollection().UpdateOne(context.TODO(), filter, update)
 		if err != nil || result.MatchedCount == 0 {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role"})
 			return
 		}
 
+		// Team links and project roles are managed through their own endpoints
+		// and stay as they are.
 		oldRoles := enforcer.GetRolesForUserInDomain(input.UserID, orgID)
 		for _, role := range oldRoles {
-			_, _ = enforcer.DeleteRoleForUserInDomain(input.UserID, role, orgID)
+			if !isTeamSubject(role) && !isProjectRole(role) {
+				_, _ = enforcer.DeleteRoleForUserInDomain(input.UserID, role, orgID)
+			}
 		}
 
 		_, err = enforcer.AddRoleForUserInDomain(input.UserID, input.Role, orgID)
@@ -208,39 +602,258 @@ func UpdateUserRoleInOrgHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
 	}
 }
 
-func GetUserOrgs(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+// GetUserOrgs lists the orgs the user belongs to. Auto-join domains are
+// applied first, so an address verified since registration takes effect here;
+// a user is only ever auto-joined to an org once.
+func GetUserOrgs(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+
+		userID := user.(models.Identity).ID
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		autoJoinVerifiedDomains(enforcer, user.(models.Identity))
+
+		orgIDs, err := db.GetMembershipCollection().Distinct(context.TODO(), "org_id", bson.M{"user_id": userID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizations"})
+			return
+		}
+		ids := make([]primitive.ObjectID, 0, len(orgIDs))
+		for _, raw := range orgIDs {
+			if id, err := primitive.ObjectIDFromHex(raw.(string)); err == nil {
+				ids = append(ids, id)
+			}
+		}
+
+		listOrgs(c, bson.M{"_id": bson.M{"$in": ids}, "archived_at": bson.M{"$exists": false}})
+	}
+}
+
+func UpdateOrganizationHandler(c *gin.Context) {
+	var input struct {
+		Name        *string `json:"name"`
+		Slug        *string `json:"slug"`
+		Description *string `json:"description"`
+		Visibility  *string `json:"visibility"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization data"})
 		return
 	}
 
-	userID := user.(models.Identity).ID
-	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	objectID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
 		return
 	}
 
-	collection := db.GetOrgCollection()
-	filter := bson.M{
-		"users": bson.M{
-			"$elemMatch": bson.M{
-				"_id": userID,
-			},
-		},
+	set := bson.M{}
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Organization name cannot be empty"})
+			return
+		}
+		taken, err := db.OrgNameTaken(context.TODO(), name, objectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": "An organization with this name already exists"})
+			return
+		}
+		set["name"] = name
 	}
-	cursor, err := collection.Find(context.TODO(), filter)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve organizations"})
+	// The slug only changes when asked for, so existing links survive a rename.
+	if input.Slug != nil {
+		if !db.ValidSlug(*input.Slug) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Slug must be lowercase letters, digits and single hyphens"})
+			return
+		}
+		set["slug"] = *input.Slug
+	}
+	if input.Description != nil {
+		set["description"] = strings.TrimSpace(*input.Description)
+	}
+	if input.Visibility != nil {
+		if !slices.Contains(orgVisibilities, *input.Visibility) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Visibility must be private, internal or public"})
+			return
+		}
+		set["visibility"] = *input.Visibility
+	}
+	if len(set) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
 		return
 	}
-	defer cursor.Close(context.TODO())
 
-	var orgs []models.Organization
-	if err := cursor.All(context.TODO(), &orgs); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode organizations"})
+	var org models.Organization
+	err = db.GetOrgCollection().FindOneAndUpdate(
+		context.TODO(),
+		bson.M{"_id": objectID},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&org)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		} else if db.IsDuplicateOrgName(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "An organization with this name already exists"})
+		} else if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Slug is already in use"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, orgs)
+	c.JSON(http.StatusOK, org)
+}
+
+func DeleteOrganizationHandler(temporalClient client.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID := c.Param("id")
+		objectID, err := primitive.ObjectIDFromHex(orgID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+			return
+		}
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+
+		var org models.Organization
+		err = db.GetOrgCollection().FindOne(context.TODO(), bson.M{"_id": objectID}).Decode(&org)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			}
+			return
+		}
+		members, err := orgMembers(context.TODO(), orgID, "")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load organization members"})
+			return
+		}
+
+		window := orgRetentionWindow()
+		// An archived org keeps its original purge time.
+		resumed := org.ArchivedAt != nil
+		if resumed {
+			window = 0
+			if org.PurgeAt != nil && time.Until(*org.PurgeAt) > 0 {
+				window = time.Until(*org.PurgeAt)
+			}
+		}
+		// The workflow ID is derived from the org, so calling delete again
+		// while a deletion is running joins it, and calling it after a failed
+		// run starts a fresh one that picks up where the old one stopped.
+		we, err := temporalClient.ExecuteWorkflow(
+			context.Background(),
+			client.StartWorkflowOptions{
+				ID:        "delete-org-" + orgID,
+				TaskQueue: "ORG_LIFECYCLE_QUEUE",
+			},
+			workflows.DeleteOrgWorkflow,
+			models.DeleteOrgInput{
+				OrgId:           orgID,
+				OrgName:         org.Name,
+				RequestedBy:     user.(models.Identity).ID,
+				Members:         members,
+				RetentionWindow: window,
+				Resumed:         resumed,
+			},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if resumed {
+			c.JSON(http.StatusAccepted, gin.H{
+				"message":     "Organization deletion resumed",
+				"purge_at":    org.PurgeAt,
+				"workflow_id": we.GetID(),
+			})
+			return
+		}
+
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.archived",
+			ActorID:  user.(models.Identity).ID,
+			TargetID: orgID,
+			Domain:   orgID,
+			Details:  map[string]interface{}{"retention_window": window.String()},
+		})
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":     "Organization archived; it will be permanently deleted after " + window.String(),
+			"purge_at":    time.Now().Add(window),
+			"workflow_id": we.GetID(),
+		})
+	}
+}
+
+// orgRetentionWindow is how long a deleted org can be restored, from
+// ORG_RETENTION_WINDOW. It defaults to 30 days.
+func orgRetentionWindow() time.Duration {
+	window, err := time.ParseDuration(os.Getenv("ORG_RETENTION_WINDOW"))
+	if err != nil || window <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return window
+}
+
+// RestoreOrganizationHandler signals the org's deletion workflow to undo the
+// archive. When the workflow isn't running any more, say because it failed
+// before the purge, the org is restored directly.
+func RestoreOrganizationHandler(enforcer *casbin.Enforcer, temporalClient client.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		org, ok := findOrg(c)
+		if !ok {
+			return
+		}
+		orgID := org.ID.Hex()
+		if org.ArchivedAt == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Organization is not archived"})
+			return
+		}
+
+		actorID := user.(models.Identity).ID
+		err := temporalClient.SignalWorkflow(context.Background(), "delete-org-"+orgID, "",
+			workflows.RestoreOrgSignal, models.RestoreOrgSignal{RequestedBy: actorID})
+		var notFound *serviceerror.NotFound
+		if errors.As(err, &notFound) {
+			restorer := &activities.CasbinActivities{Enforcer: enforcer}
+			_, err = restorer.RestoreOrgActivity(context.TODO(), orgID)
+		}
+		if err != nil {
+			fmt.Printf("Warning: Failed to restore org %s: %v\n", orgID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore organization"})
+			return
+		}
+
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.restored",
+			ActorID:  actorID,
+			TargetID: orgID,
+			Domain:   orgID,
+		})
+		c.JSON(http.StatusAccepted, gin.H{"message": "Organization restore started"})
+	}
 }
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"backend/db"
+	"backend/models"
+	"backend/utils"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func findOrg(c *gin.Context) (models.Organization, bool) {
+	var org models.Organization
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return org, false
+	}
+	err = db.GetOrgCollection().FindOne(context.TODO(), bson.M{"_id": objID}).Decode(&org)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return org, false
+	}
+	return org, true
+}
+
+// findMember returns userID's membership in org together with their Kratos
+// traits.
+func findMember(org models.Organization, userID string) (models.Member, bool) {
+	membership, err := getMembership(context.TODO(), org.ID.Hex(), userID)
+	if err != nil {
+		return models.Member{}, false
+	}
+	return resolveMember(membership), true
+}
+
+func TransferOwnershipHandler(c *gin.Context) {
+	var input struct {
+		UserID string `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+		return
+	}
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	org, ok := findOrg(c)
+	if !ok {
+		return
+	}
+
+	actorID := user.(models.Identity).ID
+	if org.CreatedBy != actorID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can transfer ownership"})
+		return
+	}
+	if input.UserID == actorID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You already own this organization"})
+		return
+	}
+	target, isMember := findMember(org, input.UserID)
+	if !isMember || target.Role != "admin" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ownership can only be transferred to an existing admin"})
+		return
+	}
+
+	transfer := models.OwnershipTransfer{
+		From:        actorID,
+		To:          target.ID,
+		RequestedAt: time.Now(),
+	}
+	_, err := db.GetOrgCollection().UpdateOne(context.TODO(),
+		bson.M{"_id": org.ID, "created_by": actorID},
+		bson.M{"$set": bson.M{"pending_transfer": transfer}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start ownership transfer"})
+		return
+	}
+
+	err = utils.TriggerNotification(target.Email, "org-ownership-transfer", map[string]interface{}{
+		"orgId":   org.ID.Hex(),
+		"orgName": org.Name,
+	})
+	if err != nil {
+		fmt.Printf("Warning: Failed to notify transfer target: %v\n", err)
+	}
+	_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+		Action:   "org.ownership_transfer_requested",
+		ActorID:  actorID,
+		TargetID: target.ID,
+		Domain:   org.ID.Hex(),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ownership transfer requested", "pending_transfer": transfer})
+}
+
+func AcceptOwnershipHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		org, ok := findOrg(c)
+		if !ok {
+			return
+		}
+
+		actorID := user.(models.Identity).ID
+		orgID := org.ID.Hex()
+		transfer := org.PendingTransfer
+		if transfer == nil || transfer.To != actorID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No pending ownership transfer for you"})
+			return
+		}
+		if member, isMember := findMember(org, actorID); !isMember || member.Role != "admin" {
+			c.JSON(http.StatusConflict, gin.H{"error": "You must still be an admin to accept ownership"})
+			return
+		}
+
+		result, err := db.GetOrgCollection().UpdateOne(context.TODO(),
+			bson.M{"_id": org.ID, "created_by": transfer.From, "pending_transfer.to": actorID},
+			bson.M{
+				"$set":   bson.M{"created_by": actorID},
+				"$unset": bson.M{"pending_transfer": ""},
+			},
+		)
+		if err != nil || result.ModifiedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Ownership transfer is no longer pending"})
+			return
+		}
+
+		// The new owner was checked to be an admin above, and the previous
+		// owner stays one, so no roles change.
+
+		if previous, isMember := findMember(org, transfer.From); isMember {
+			err = utils.TriggerNotification(previous.Email, "org-ownership-transferred", map[string]interface{}{
+				"orgId":   orgID,
+				"orgName": org.Name,
+			})
+			if err != nil {
+				fmt.Printf("Warning: Failed to notify previous owner: %v\n", err)
+			}
+		}
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.ownership_transferred",
+			ActorID:  actorID,
+			TargetID: actorID,
+			Domain:   orgID,
+			Details:  map[string]interface{}{"from": transfer.From, "to": actorID},
+		})
+
+		c.JSON(http.StatusOK, gin.H{"message": "You are now the owner of this organization"})
+	}
+}
+
+// DeclineOwnershipHandler lets the nominee decline, or the owner withdraw, a
+// pending transfer.
+func DeclineOwnershipHandler(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	org, ok := findOrg(c)
+	if !ok {
+		return
+	}
+
+	actorID := user.(models.Identity).ID
+	transfer := org.PendingTransfer
+	if transfer == nil || (transfer.To != actorID && transfer.From != actorID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No pending ownership transfer"})
+		return
+	}
+
+	// Only the transfer checked above is cancelled, not a newer one.
+	result, err := db.GetOrgCollection().UpdateOne(context.TODO(),
+		bson.M{"_id": org.ID, "pending_transfer.to": transfer.To, "pending_transfer.from": transfer.From},
+		bson.M{"$unset": bson.M{"pending_transfer": ""}},
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel ownership transfer"})
+		return
+	}
+	if result.ModifiedCount == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ownership transfer is no longer pending"})
+		return
+	}
+	_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+		Action:   "org.ownership_transfer_cancelled",
+		ActorID:  actorID,
+		TargetID: transfer.To,
+		Domain:   org.ID.Hex(),
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ownership transfer cancelled"})
+}
+
+func GetOrgAuditHandler(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	filter := bson.M{"domain": c.Param("id")}
+	if action := c.Query("action"); action != "" {
+		filter["action"] = action
+	}
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(limit)
+	cursor, err := db.GetAuditCollection().Find(context.TODO(), filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log"})
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	entries := []models.AuditEntry{}
+	if err := cursor.All(context.TODO(), &entries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode audit log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
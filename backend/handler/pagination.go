@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// pageCursor marks the last item of a page: its value in the sort field and a
+// tiebreaker ID. It travels to clients as opaque base64.
+type pageCursor struct {
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+type pageParams struct {
+	Limit  int64
+	Cursor *pageCursor
+	Sort   string
+	Desc   bool
+	Prefix string
+	Search string
+}
+
+// parsePageParams reads limit, cursor, sort, order, q (prefix) and search
+// from the query string. allowed lists the accepted sort fields; the first
+// one is the default.
+func parsePageParams(c *gin.Context, allowed ...string) (pageParams, error) {
+	p := pageParams{
+		Limit:  defaultPageLimit,
+		Sort:   allowed[0],
+		Prefix: strings.TrimSpace(c.Query("q")),
+		Search: strings.TrimSpace(c.Query("search")),
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || limit <= 0 {
+			return p, errors.New("limit must be a positive number")
+		}
+		p.Limit = min(limit, maxPageLimit)
+	}
+	if sort := c.Query("sort"); sort != "" {
+		valid := false
+		for _, field := range allowed {
+			if sort == field {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return p, errors.New("sort must be one of " + strings.Join(allowed, ", "))
+		}
+		p.Sort = sort
+	}
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		p.Desc = true
+	default:
+		return p, errors.New("order must be asc or desc")
+	}
+	if raw := c.Query("cursor"); raw != "" {
+		data, err := base64.RawURLEncoding.DecodeString(raw)
+		if err != nil {
+			return p, errors.New("invalid cursor")
+		}
+		var cursor pageCursor
+		if err := json.Unmarshal(data, &cursor); err != nil {
+			return p, errors.New("invalid cursor")
+		}
+		p.Cursor = &cursor
+	}
+	return p, nil
+}
+
+func encodeCursor(value, id string) string {
+	data, _ := json.Marshal(pageCursor{Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// cursorValue converts the cursor's string form back into the type stored in
+// the sort field.
+func cursorValue(field, value string) interface{} {
+	if strings.HasSuffix(field, "_at") {
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t
+		}
+	}
+	return value
+}
+
+// afterCursor builds the keyset condition that selects documents strictly
+// after the cursor in (field, idField) order.
+func (p pageParams) afterCursor(field, idField string, id interface{}) bson.M {
+	op := "$gt"
+	if p.Desc {
+		op = "$lt"
+	}
+	value := cursorValue(field, p.Cursor.Value)
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{op: value}},
+		bson.M{field: value, idField: bson.M{op: id}},
+	}}
+}
+
+func (p pageParams) sortSpec(field, idField string) bson.D {
+	dir := 1
+	if p.Desc {
+		dir = -1
+	}
+	return bson.D{{Key: field, Value: dir}, {Key: idField, Value: dir}}
+}
+
+func prefixPattern(prefix string) bson.M {
+	return bson.M{"$regex": "^" + regexp.QuoteMeta(prefix), "$options": "i"}
+}
+
+func containsPattern(text string) bson.M {
+	return bson.M{"$regex": regexp.QuoteMeta(text), "$options": "i"}
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"backend/db"
+	"backend/models"
+	"backend/plans"
+	"backend/utils"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.temporal.io/sdk/temporal"
+)
+
+// respondLimitError writes a 403 naming the plan limit that was hit. It
+// reports false when err is not a limit error.
+func respondLimitError(c *gin.Context, err error) bool {
+	var limitErr *plans.LimitError
+	if errors.As(err, &limitErr) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":   limitErr.Error(),
+			"plan":    limitErr.Plan,
+			"limit":   limitErr.Limit,
+			"max":     limitErr.Max,
+			"current": limitErr.Current,
+		})
+		return true
+	}
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) && appErr.Type() == "LimitExceeded" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": appErr.Message()})
+		return true
+	}
+	return false
+}
+
+// checkLimits runs plans.Check for each limit, writing the response for the
+// first one that fails.
+func checkLimits(c *gin.Context, orgID string, limits ...string) bool {
+	for _, limit := range limits {
+		if err := plans.Check(context.TODO(), orgID, limit); err != nil {
+			if !respondLimitError(c, err) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check plan limits"})
+			}
+			return false
+		}
+	}
+	return true
+}
+
+func GetOrgUsageHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	if !primitive.IsValidObjectID(orgID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+	usage, err := plans.OrgUsage(context.TODO(), orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load usage"})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+// UpdateOrgPlanHandler moves an org to another plan. Downgrades are allowed
+// even when the org is over the new limits; it just cannot grow further.
+func UpdateOrgPlanHandler(c *gin.Context) {
+	var input struct {
+		OrgID string `json:"org_id" binding:"required"`
+		Plan  string `json:"plan" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+		return
+	}
+	if _, ok := plans.Plans[input.Plan]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown plan"})
+		return
+	}
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	orgID, err := db.ResolveOrgID(context.TODO(), input.OrgID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+	objID, _ := primitive.ObjectIDFromHex(orgID)
+
+	res, err := db.GetOrgCollection().UpdateOne(context.TODO(), bson.M{"_id": objID}, bson.M{"$set": bson.M{"plan": input.Plan}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update plan"})
+		return
+	}
+	if res.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+		Action:   "org.plan_changed",
+		ActorID:  user.(models.Identity).ID,
+		TargetID: orgID,
+		Domain:   orgID,
+		Details:  map[string]interface{}{"plan": input.Plan},
+	})
+	usage, err := plans.OrgUsage(context.TODO(), orgID)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"plan": input.Plan})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
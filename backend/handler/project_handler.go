@@ -0,0 +1,498 @@
+package handler
+
+import (
+	"backend/db"
+	"backend/models"
+	"backend/utils"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var projectRoles = []string{"admin", "writer", "reader"}
+
+// Project actions, checked against the project object once the org route has
+// been authorized.
+const (
+	projectRead  = "read"
+	projectWrite = "write"
+	projectAdmin = "admin"
+)
+
+func projectRole(projectID, role string) string {
+	return "project:" + projectID + ":" + role
+}
+
+func isProjectRole(role string) bool {
+	return strings.HasPrefix(role, "project:")
+}
+
+func projectObject(projectID string) string {
+	return "project:" + projectID
+}
+
+// projectPolicies are the rules every project gets in its org domain. Project
+// roles form their own admin → writer → reader chain, and each org role holds
+// the matching role in every project.
+func projectPolicies(orgID, projectID string) ([][]string, [][]string) {
+	obj := projectObject(projectID)
+	policies := [][]string{
+		{projectRole(projectID, "reader"), orgID, obj, projectRead},
+		{projectRole(projectID, "writer"), orgID, obj, projectWrite},
+		{projectRole(projectID, "admin"), orgID, obj, projectAdmin},
+	}
+	grouping := [][]string{
+		{projectRole(projectID, "admin"), projectRole(projectID, "writer"), orgID},
+		{projectRole(projectID, "writer"), projectRole(projectID, "reader"), orgID},
+		{"admin", projectRole(projectID, "admin"), orgID},
+		{"writer", projectRole(projectID, "writer"), orgID},
+		{"reader", projectRole(projectID, "reader"), orgID},
+	}
+	return policies, grouping
+}
+
+// authorizeProject checks the user's access to the project itself, on top
+// of the org route check done by the middleware.
+func authorizeProject(c *gin.Context, enforcer *casbin.Enforcer, project models.Project, action string) bool {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return false
+	}
+	ok, err := enforcer.Enforce(user.(models.Identity).ID, project.OrgID, projectObject(project.ID.Hex()), action)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal error"})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return false
+	}
+	return true
+}
+
+func findProject(c *gin.Context, orgID, projectID string) (models.Project, bool) {
+	var project models.Project
+	objID, err := primitive.ObjectIDFromHex(projectID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return project, false
+	}
+	err = db.GetProjectCollection().FindOne(context.TODO(), bson.M{"_id": objID, "org_id": orgID}).Decode(&project)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return project, false
+	}
+	return project, true
+}
+
+// removeFromProjects drops userID from every project in the org. The matching
+// g rules are left to the caller.
+func removeFromProjects(ctx context.Context, orgID, userID string) error {
+	_, err := db.GetProjectMemberCollection().DeleteMany(ctx, bson.M{"org_id": orgID, "user_id": userID})
+	return err
+}
+
+func CreateProjectHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Name        string `json:"name" binding:"required"`
+			Description string `json:"description"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project data"})
+			return
+		}
+		name := strings.TrimSpace(input.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Project name cannot be empty"})
+			return
+		}
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		org, ok := findOrg(c)
+		if !ok {
+			return
+		}
+		orgID := org.ID.Hex()
+		actorID := user.(models.Identity).ID
+
+		project := models.Project{
+			OrgID:       orgID,
+			Name:        name,
+			Description: strings.TrimSpace(input.Description),
+			CreatedBy:   actorID,
+			CreatedAt:   time.Now(),
+		}
+		res, err := db.GetProjectCollection().InsertOne(context.TODO(), project)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "A project with this name already exists"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create project"})
+			}
+			return
+		}
+		project.ID = res.InsertedID.(primitive.ObjectID)
+		projectID := project.ID.Hex()
+
+		policies, grouping := projectPolicies(orgID, projectID)
+		if _, err := enforcer.AddPolicies(policies); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign policies"})
+			return
+		}
+		if _, err := enforcer.AddNamedGroupingPolicies("g", grouping); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign role"})
+			return
+		}
+
+		// The creator administers the project.
+		_, err = db.GetProjectMemberCollection().InsertOne(context.TODO(), models.ProjectMember{
+			ProjectID: projectID,
+			OrgID:     orgID,
+			UserID:    actorID,
+			Role:      "admin",
+			AddedBy:   actorID,
+			AddedAt:   time.Now(),
+		})
+		if err == nil {
+			_, err = enforcer.AddRoleForUserInDomain(actorID, projectRole(projectID, "admin"), orgID)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add project admin"})
+			return
+		}
+
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.project_created",
+			ActorID:  actorID,
+			TargetID: projectID,
+			Domain:   orgID,
+			Details:  map[string]interface{}{"name": project.Name},
+		})
+		c.JSON(http.StatusCreated, project)
+	}
+}
+
+// GetProjectsHandler lists the org's projects the user can read.
+func GetProjectsHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		orgID := c.Param("id")
+		userID := user.(models.Identity).ID
+
+		cursor, err := db.GetProjectCollection().Find(context.TODO(), bson.M{"org_id": orgID},
+			options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve projects"})
+			return
+		}
+		defer cursor.Close(context.TODO())
+
+		var all []models.Project
+		if err := cursor.All(context.TODO(), &all); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode projects"})
+			return
+		}
+		projects := []models.Project{}
+		for _, project := range all {
+			if ok, _ := enforcer.Enforce(userID, orgID, projectObject(project.ID.Hex()), projectRead); ok {
+				projects = append(projects, project)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"data": projects})
+	}
+}
+
+func GetProjectHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		project, ok := findProject(c, c.Param("id"), c.Query("project_id"))
+		if !ok || !authorizeProject(c, enforcer, project, projectRead) {
+			return
+		}
+		user, _ := c.Get("user")
+		roles, _ := enforcer.GetImplicitRolesForUser(user.(models.Identity).ID, project.OrgID)
+		role := "none"
+		for _, r := range projectRoles {
+			if slices.Contains(roles, projectRole(project.ID.Hex(), r)) {
+				role = r
+				break
+			}
+		}
+
+		repos := []models.Repo{}
+		cursor, err := db.GetRepoCollection().Find(context.TODO(), bson.M{"project_id": project.ID.Hex()},
+			options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+		if err == nil {
+			_ = cursor.All(context.TODO(), &repos)
+		}
+		c.JSON(http.StatusOK, gin.H{"project": project, "role": role, "repos": repos})
+	}
+}
+
+func UpdateProjectHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			ProjectID   string  `json:"project_id" binding:"required"`
+			Name        *string `json:"name"`
+			Description *string `json:"description"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project data"})
+			return
+		}
+		project, ok := findProject(c, c.Param("id"), input.ProjectID)
+		if !ok || !authorizeProject(c, enforcer, project, projectAdmin) {
+			return
+		}
+
+		set := bson.M{}
+		if input.Name != nil {
+			name := strings.TrimSpace(*input.Name)
+			if name == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Project name cannot be empty"})
+				return
+			}
+			set["name"] = name
+		}
+		if input.Description != nil {
+			set["description"] = strings.TrimSpace(*input.Description)
+		}
+		if len(set) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+			return
+		}
+
+		err := db.GetProjectCollection().FindOneAndUpdate(
+			context.TODO(),
+			bson.M{"_id": project.ID},
+			bson.M{"$set": set},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&project)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "A project with this name already exists"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
+			}
+			return
+		}
+		c.JSON(http.StatusOK, project)
+	}
+}
+
+// DeleteProjectHandler removes a project, its members and its rules. Repos
+// stay with the org and are only detached from the project.
+func DeleteProjectHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		project, ok := findProject(c, c.Param("id"), c.Query("project_id"))
+		if !ok || !authorizeProject(c, enforcer, project, projectAdmin) {
+			return
+		}
+		user, _ := c.Get("user")
+		projectID := project.ID.Hex()
+		orgID := project.OrgID
+
+		if _, err := db.GetProjectMemberCollection().DeleteMany(context.TODO(), bson.M{"project_id": projectID}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove project members"})
+			return
+		}
+		if _, err := db.GetRepoCollection().UpdateMany(context.TODO(), bson.M{"project_id": projectID},
+			bson.M{"$unset": bson.M{"project_id": ""}}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to detach project repos"})
+			return
+		}
+		if _, err := db.GetProjectCollection().DeleteOne(context.TODO(), bson.M{"_id": project.ID}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project"})
+			return
+		}
+
+		_, _ = enforcer.RemoveFilteredPolicy(1, orgID, projectObject(projectID))
+		for _, role := range projectRoles {
+			_, _ = enforcer.RemoveFilteredGroupingPolicy(0, projectRole(projectID, role), "", orgID)
+			_, _ = enforcer.RemoveFilteredGroupingPolicy(1, projectRole(projectID, role), orgID)
+		}
+
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.project_deleted",
+			ActorID:  user.(models.Identity).ID,
+			TargetID: projectID,
+			Domain:   orgID,
+			Details:  map[string]interface{}{"name": project.Name},
+		})
+		c.JSON(http.StatusOK, gin.H{"message": "Project deleted"})
+	}
+}
+
+func GetProjectMembersHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		project, ok := findProject(c, c.Param("id"), c.Query("project_id"))
+		if !ok || !authorizeProject(c, enforcer, project, projectRead) {
+			return
+		}
+
+		cursor, err := db.GetProjectMemberCollection().Find(context.TODO(), bson.M{"project_id": project.ID.Hex()},
+			options.Find().SetSort(bson.D{{Key: "added_at", Value: 1}}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve project members"})
+			return
+		}
+		defer cursor.Close(context.TODO())
+
+		var projectMembers []models.ProjectMember
+		if err := cursor.All(context.TODO(), &projectMembers); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode project members"})
+			return
+		}
+		dir, err := identityDirectory()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load identities"})
+			return
+		}
+
+		members := make([]models.Member, 0, len(projectMembers))
+		for _, pm := range projectMembers {
+			identity := dir[pm.UserID]
+			members = append(members, models.Member{
+				ID:        pm.UserID,
+				Email:     identity.Traits.Email,
+				Name:      identity.Traits.Name,
+				Role:      pm.Role,
+				JoinedAt:  pm.AddedAt,
+				InvitedBy: pm.AddedBy,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{"project": project, "data": members})
+	}
+}
+
+// AddProjectMemberHandler gives an org member a role in the project, or
+// changes the role they already have.
+func AddProjectMemberHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			ProjectID string `json:"project_id" binding:"required"`
+			UserID    string `json:"user_id" binding:"required"`
+			Role      string `json:"role" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+			return
+		}
+		if !slices.Contains(projectRoles, input.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be one of admin, writer or reader"})
+			return
+		}
+		project, ok := findProject(c, c.Param("id"), input.ProjectID)
+		if !ok || !authorizeProject(c, enforcer, project, projectAdmin) {
+			return
+		}
+		user, _ := c.Get("user")
+		actorID := user.(models.Identity).ID
+		projectID := project.ID.Hex()
+		orgID := project.OrgID
+
+		if _, err := getMembership(context.TODO(), orgID, input.UserID); err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusBadRequest, gin.H{"error": errMemberNotFound.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			}
+			return
+		}
+
+		_, err := db.GetProjectMemberCollection().UpdateOne(context.TODO(),
+			bson.M{"project_id": projectID, "user_id": input.UserID},
+			bson.M{
+				"$set": bson.M{"role": input.Role},
+				"$setOnInsert": bson.M{
+					"org_id":   orgID,
+					"added_by": actorID,
+					"added_at": time.Now(),
+				},
+			},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add project member"})
+			return
+		}
+		for _, role := range projectRoles {
+			_, _ = enforcer.DeleteRoleForUserInDomain(input.UserID, projectRole(projectID, role), orgID)
+		}
+		if _, err := enforcer.AddRoleForUserInDomain(input.UserID, projectRole(projectID, input.Role), orgID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign project role"})
+			return
+		}
+
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.project_member_added",
+			ActorID:  actorID,
+			TargetID: input.UserID,
+			Domain:   orgID,
+			Details:  map[string]interface{}{"project_id": projectID, "role": input.Role},
+		})
+		c.JSON(http.StatusOK, gin.H{"message": "Project role updated"})
+	}
+}
+
+func RemoveProjectMemberHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			ProjectID string `json:"project_id" binding:"required"`
+			UserID    string `json:"user_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+			return
+		}
+		project, ok := findProject(c, c.Param("id"), input.ProjectID)
+		if !ok || !authorizeProject(c, enforcer, project, projectAdmin) {
+			return
+		}
+		user, _ := c.Get("user")
+		projectID := project.ID.Hex()
+
+		res, err := db.GetProjectMemberCollection().DeleteOne(context.TODO(), bson.M{"project_id": projectID, "user_id": input.UserID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove project member"})
+			return
+		}
+		if res.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User is not a member of this project"})
+			return
+		}
+		for _, role := range projectRoles {
+			_, _ = enforcer.DeleteRoleForUserInDomain(input.UserID, projectRole(projectID, role), project.OrgID)
+		}
+
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.project_member_removed",
+			ActorID:  user.(models.Identity).ID,
+			TargetID: input.UserID,
+			Domain:   project.OrgID,
+			Details:  map[string]interface{}{"project_id": projectID},
+		})
+		c.JSON(http.StatusOK, gin.H{"message": "User removed from project"})
+	}
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"backend/models"
+	"backend/secrets"
+	"backend/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func respondSecretError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, secrets.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, secrets.ErrNoMasterKey), errors.Is(err, secrets.ErrMasterKeyMismatch):
+		fmt.Printf("Warning: secret store unavailable: %v\n", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Secret store is not available"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Secret store error"})
+	}
+}
+
+// GetSecretsHandler lists the org's secrets without their values.
+func GetSecretsHandler(c *gin.Context) {
+	list, err := secrets.List(context.TODO(), c.Param("id"))
+	if err != nil {
+		respondSecretError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+func GetSecretVersionsHandler(c *gin.Context) {
+	versions, err := secrets.Versions(context.TODO(), c.Param("id"), c.Query("name"))
+	if err != nil {
+		respondSecretError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": versions})
+}
+
+// PutSecretHandler stores a new version of a secret. Earlier versions are
+// kept and can still be read by version number.
+func PutSecretHandler(c *gin.Context) {
+	var input struct {
+		Name      string   `json:"name" binding:"required"`
+		Value     string   `json:"value" binding:"required"`
+		ReadRoles []string `json:"read_roles"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid secret data"})
+		return
+	}
+	if !secrets.ValidName(input.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Secret names start with a letter and use letters, digits, '_', '.' or '-'"})
+		return
+	}
+	if len(input.Value) > secrets.MaxValueSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Secret value is too large"})
+		return
+	}
+	for _, role := range input.ReadRoles {
+		if !slices.Contains(teamRoles, role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Read roles must be admin, writer or reader"})
+			return
+		}
+	}
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	orgID := c.Param("id")
+	actorID := user.(models.Identity).ID
+
+	secret, err := secrets.Put(context.TODO(), orgID, input.Name, input.Value, input.ReadRoles, actorID)
+	if err != nil {
+		respondSecretError(c, err)
+		return
+	}
+
+	_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+		Action:   "org.secret_written",
+		ActorID:  actorID,
+		TargetID: secret.Name,
+		Domain:   orgID,
+		Details:  map[string]interface{}{"version": secret.Version, "read_roles": secret.ReadRoles},
+	})
+	c.JSON(http.StatusCreated, secret)
+}
+
+// ReadSecretHandler returns a secret value to members holding one of its read
+// roles. Every attempt is audited, including denied ones.
+func ReadSecretHandler(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	orgID := c.Param("id")
+	name := c.Query("name")
+	version := 0
+	if v := c.Query("version"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+			return
+		}
+		version = n
+	}
+
+	latest, err := secrets.Latest(context.TODO(), orgID, name)
+	if err != nil {
+		respondSecretError(c, err)
+		return
+	}
+	entry := models.AuditEntry{
+		Action:   "org.secret_read",
+		ActorID:  user.(models.Identity).ID,
+		TargetID: name,
+		Domain:   orgID,
+		Details:  map[string]interface{}{"version": version},
+	}
+	if !secrets.CanRead(latest, c.GetStringSlice("org_roles")) {
+		entry.Action = "org.secret_read_denied"
+		_ = utils.RecordAudit(context.TODO(), entry)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	secret, value, err := secrets.Get(context.TODO(), orgID, name, version)
+	if err != nil {
+		respondSecretError(c, err)
+		return
+	}
+	entry.Details["version"] = secret.Version
+	_ = utils.RecordAudit(context.TODO(), entry)
+	c.JSON(http.StatusOK, gin.H{"secret": secret, "value": value})
+}
+
+func DeleteSecretHandler(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	orgID := c.Param("id")
+	name := c.Query("name")
+	if err := secrets.Delete(context.TODO(), orgID, name); err != nil {
+		respondSecretError(c, err)
+		return
+	}
+
+	_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+		Action:   "org.secret_deleted",
+		ActorID:  user.(models.Identity).ID,
+		TargetID: name,
+		Domain:   orgID,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Secret deleted"})
+}
@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"backend/db"
+	"backend/logostore"
+	"backend/models"
+	"backend/orgsettings"
+	"backend/utils"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxLogoSize = 1 << 20
+
+// logoTypes maps the accepted logo content types to the extension they are
+// stored with. SVG is left out since it can carry scripts.
+var logoTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// currentSettings returns the org's settings in the current schema, answering
+// 409 when they were saved by a newer server.
+func currentSettings(c *gin.Context, org models.Organization) (models.OrgSettings, bool) {
+	settings, err := orgsettings.Upgrade(org.Settings)
+	if errors.Is(err, orgsettings.ErrNewerSchema) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return settings, false
+	}
+	return settings, true
+}
+
+// saveSettings stores settings unless a newer server has written the
+// document in the meantime.
+func saveSettings(ctx context.Context, orgID primitive.ObjectID, settings models.OrgSettings, userID string) (models.Organization, error) {
+	now := time.Now()
+	settings.SchemaVersion = orgsettings.SchemaVersion
+	settings.UpdatedBy = userID
+	settings.UpdatedAt = &now
+
+	var org models.Organization
+	err := db.GetOrgCollection().FindOneAndUpdate(ctx,
+		bson.M{"_id": orgID, "settings.schema_version": bson.M{"$not": bson.M{"$gt": orgsettings.SchemaVersion}}},
+		bson.M{"$set": bson.M{"settings": settings}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&org)
+	if err == mongo.ErrNoDocuments {
+		return org, orgsettings.ErrNewerSchema
+	}
+	return org, err
+}
+
+func respondSettingsError(c *gin.Context, err error) {
+	if errors.Is(err, orgsettings.ErrNewerSchema) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings"})
+}
+
+// deleteLogo removes a replaced logo. A leftover file is harmless, so
+// failures are only logged.
+func deleteLogo(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+	if err := logostore.Delete(ctx, key); err != nil && !errors.Is(err, logostore.ErrNotFound) {
+		fmt.Printf("Warning: Failed to delete logo %s: %v\n", key, err)
+	}
+}
+
+func GetOrgSettingsHandler(c *gin.Context) {
+	org, ok := findOrg(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, orgsettings.Public(org.ID.Hex(), org.Settings))
+}
+
+// UpdateOrgSettingsHandler changes the fields present in the body. Setting
+// logo_url replaces an uploaded logo; an empty logo_url removes it.
+func UpdateOrgSettingsHandler(c *gin.Context) {
+	var input struct {
+		LogoURL      *string `json:"logo_url"`
+		PrimaryColor *string `json:"primary_color"`
+		AccentColor  *string `json:"accent_color"`
+		LandingPage  *string `json:"landing_page"`
+		ContactEmail *string `json:"contact_email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid settings"})
+		return
+	}
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	org, ok := findOrg(c)
+	if !ok {
+		return
+	}
+	settings, ok := currentSettings(c, org)
+	if !ok {
+		return
+	}
+
+	var replacedLogo string
+	if input.LogoURL != nil {
+		replacedLogo = settings.Branding.LogoKey
+		settings.Branding.LogoURL = *input.LogoURL
+		settings.Branding.LogoKey = ""
+		settings.Branding.LogoContentType = ""
+	}
+	if input.PrimaryColor != nil {
+		settings.Branding.PrimaryColor = *input.PrimaryColor
+	}
+	if input.AccentColor != nil {
+		settings.Branding.AccentColor = *input.AccentColor
+	}
+	if input.LandingPage != nil {
+		settings.LandingPage = *input.LandingPage
+	}
+	if input.ContactEmail != nil {
+		settings.ContactEmail = *input.ContactEmail
+	}
+	if err := orgsettings.Normalize(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorID := user.(models.Identity).ID
+	updated, err := saveSettings(context.TODO(), org.ID, settings, actorID)
+	if err != nil {
+		respondSettingsError(c, err)
+		return
+	}
+	deleteLogo(context.TODO(), replacedLogo)
+
+	_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+		Action:   "org.settings_updated",
+		ActorID:  actorID,
+		TargetID: org.ID.Hex(),
+		Domain:   org.ID.Hex(),
+	})
+	c.JSON(http.StatusOK, orgsettings.Public(org.ID.Hex(), updated.Settings))
+}
+
+// UploadOrgLogoHandler stores the "logo" form file (PNG, JPEG, GIF or WebP,
+// up to 1 MB) as the org's logo, replacing any logo URL.
+func UploadOrgLogoHandler(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	org, ok := findOrg(c)
+	if !ok {
+		return
+	}
+	settings, ok := currentSettings(c, org)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxLogoSize+64<<10)
+	header, err := c.FormFile("logo")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing or oversized logo file"})
+		return
+	}
+	if header.Size > maxLogoSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Logo must be at most 1 MB"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read logo"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxLogoSize+1))
+	file.Close()
+	if err != nil || len(data) > maxLogoSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read logo"})
+		return
+	}
+	// The type is sniffed from the content; the client's claim isn't trusted.
+	contentType := http.DetectContentType(data)
+	ext, ok := logoTypes[contentType]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Logo must be a PNG, JPEG, GIF or WebP image"})
+		return
+	}
+
+	key := org.ID.Hex() + "/" + uuid.NewString() + ext
+	if err := logostore.Put(context.TODO(), key, contentType, data); err != nil {
+		fmt.Printf("Warning: Failed to store logo for org %s: %v\n", org.ID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store logo"})
+		return
+	}
+
+	replacedLogo := settings.Branding.LogoKey
+	settings.Branding.LogoURL = ""
+	settings.Branding.LogoKey = key
+	settings.Branding.LogoContentType = contentType
+	actorID := user.(models.Identity).ID
+	updated, err := saveSettings(context.TODO(), org.ID, settings, actorID)
+	if err != nil {
+		deleteLogo(context.TODO(), key)
+		respondSettingsError(c, err)
+		return
+	}
+	deleteLogo(context.TODO(), replacedLogo)
+
+	_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+		Action:   "org.logo_uploaded",
+		ActorID:  actorID,
+		TargetID: org.ID.Hex(),
+		Domain:   org.ID.Hex(),
+	})
+	c.JSON(http.StatusOK, orgsettings.Public(org.ID.Hex(), updated.Settings))
+}
+
+// DeleteOrgLogoHandler removes the org's logo, uploaded or linked.
+func DeleteOrgLogoHandler(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+		return
+	}
+	org, ok := findOrg(c)
+	if !ok {
+		return
+	}
+	settings, ok := currentSettings(c, org)
+	if !ok {
+		return
+	}
+
+	replacedLogo := settings.Branding.LogoKey
+	settings.Branding.LogoURL = ""
+	settings.Branding.LogoKey = ""
+	settings.Branding.LogoContentType = ""
+	updated, err := saveSettings(context.TODO(), org.ID, settings, user.(models.Identity).ID)
+	if err != nil {
+		respondSettingsError(c, err)
+		return
+	}
+	deleteLogo(context.TODO(), replacedLogo)
+	c.JSON(http.StatusOK, orgsettings.Public(org.ID.Hex(), updated.Settings))
+}
+
+// GetOrgLogoHandler serves an uploaded logo. It needs no session, since
+// invite emails link to it; archived orgs' logos are not served.
+func GetOrgLogoHandler(c *gin.Context) {
+	org, ok := findOrg(c)
+	if !ok {
+		return
+	}
+	if org.ArchivedAt != nil || org.Settings == nil || org.Settings.Branding.LogoKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Logo not found"})
+		return
+	}
+	data, contentType, err := logostore.Get(context.TODO(), org.Settings.Branding.LogoKey)
+	if err != nil {
+		if errors.Is(err, logostore.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Logo not found"})
+		} else {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to load logo"})
+		}
+		return
+	}
+	if org.Settings.Branding.LogoContentType != "" {
+		contentType = org.Settings.Branding.LogoContentType
+	}
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, contentType, data)
+}
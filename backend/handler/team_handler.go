@@ -0,0 +1,466 @@
+package handler
+
+import (
+	"backend/db"
+	"backend/models"
+	"backend/policy"
+	"backend/utils"
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var teamRoles = []string{"admin", "writer", "reader"}
+
+// teamSubject is the Casbin subject for a team. Members are linked to it with
+// g rules, and it is linked to its org role the same way.
+func teamSubject(teamID string) string {
+	return "team:" + teamID
+}
+
+func isTeamSubject(role string) bool {
+	return strings.HasPrefix(role, "team:")
+}
+
+// findTeam loads the team named by teamID, making sure it belongs to the org
+// in the route.
+func findTeam(c *gin.Context, teamID string) (models.Team, bool) {
+	var team models.Team
+	objID, err := primitive.ObjectIDFromHex(teamID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+		return team, false
+	}
+	err = db.GetTeamCollection().FindOne(context.TODO(), bson.M{"_id": objID, "org_id": c.Param("id")}).Decode(&team)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return team, false
+	}
+	return team, true
+}
+
+func teamMemberIDs(ctx context.Context, teamID string) ([]string, error) {
+	raw, err := db.GetTeamMemberCollection().Distinct(ctx, "user_id", bson.M{"team_id": teamID})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(raw))
+	for _, id := range raw {
+		ids = append(ids, id.(string))
+	}
+	return ids, nil
+}
+
+// removeFromTeams drops userID from every team in the org. The matching g
+// rules are left to the caller.
+func removeFromTeams(ctx context.Context, orgID, userID string) error {
+	_, err := db.GetTeamMemberCollection().DeleteMany(ctx, bson.M{"org_id": orgID, "user_id": userID})
+	return err
+}
+
+// joinTeam records userID as a member of team and links them to the team
+// subject in the org domain.
+func joinTeam(ctx context.Context, enforcer *casbin.Enforcer, team models.Team, userID, addedBy string) error {
+	_, err := db.GetTeamMemberCollection().InsertOne(ctx, models.TeamMember{
+		TeamID:  team.ID.Hex(),
+		OrgID:   team.OrgID,
+		UserID:  userID,
+		AddedBy: addedBy,
+		AddedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = enforcer.AddRoleForUserInDomain(userID, teamSubject(team.ID.Hex()), team.OrgID)
+	return err
+}
+
+func CreateTeamHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Name        string `json:"name" binding:"required"`
+			Description string `json:"description"`
+			Role        string `json:"role" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team data"})
+			return
+		}
+		if !slices.Contains(teamRoles, input.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be one of admin, writer or reader"})
+			return
+		}
+		name := strings.TrimSpace(input.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Team name cannot be empty"})
+			return
+		}
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		org, ok := findOrg(c)
+		if !ok {
+			return
+		}
+		orgID := org.ID.Hex()
+		actorID := user.(models.Identity).ID
+
+		team := models.Team{
+			OrgID:       orgID,
+			Name:        name,
+			Description: strings.TrimSpace(input.Description),
+			Role:        input.Role,
+			CreatedBy:   actorID,
+			CreatedAt:   time.Now(),
+		}
+		res, err := db.GetTeamCollection().InsertOne(context.TODO(), team)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "A team with this name already exists"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create team"})
+			}
+			return
+		}
+		team.ID = res.InsertedID.(primitive.ObjectID)
+
+		if _, err := enforcer.AddRoleForUserInDomain(teamSubject(team.ID.Hex()), team.Role, orgID); err != nil {
+			_, _ = db.GetTeamCollection().DeleteOne(context.TODO(), bson.M{"_id": team.ID})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign team role"})
+			return
+		}
+
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.team_created",
+			ActorID:  actorID,
+			TargetID: team.ID.Hex(),
+			Domain:   orgID,
+			Details:  map[string]interface{}{"name": team.Name, "role": team.Role},
+		})
+		c.JSON(http.StatusCreated, team)
+	}
+}
+
+func GetTeamsHandler(c *gin.Context) {
+	orgID := c.Param("id")
+	if !primitive.IsValidObjectID(orgID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	cursor, err := db.GetTeamCollection().Find(context.TODO(), bson.M{"org_id": orgID},
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve teams"})
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	teams := []models.Team{}
+	if err := cursor.All(context.TODO(), &teams); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode teams"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": teams})
+}
+
+// UpdateTeamHandler renames a team or changes the org role its members get
+// through it.
+func UpdateTeamHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			TeamID      string  `json:"team_id" binding:"required"`
+			Name        *string `json:"name"`
+			Description *string `json:"description"`
+			Role        *string `json:"role"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team data"})
+			return
+		}
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		team, ok := findTeam(c, input.TeamID)
+		if !ok {
+			return
+		}
+		orgID := team.OrgID
+		subject := teamSubject(team.ID.Hex())
+
+		set := bson.M{}
+		if input.Name != nil {
+			name := strings.TrimSpace(*input.Name)
+			if name == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Team name cannot be empty"})
+				return
+			}
+			set["name"] = name
+		}
+		if input.Description != nil {
+			set["description"] = strings.TrimSpace(*input.Description)
+		}
+		if input.Role != nil && *input.Role != team.Role {
+			if !slices.Contains(teamRoles, *input.Role) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be one of admin, writer or reader"})
+				return
+			}
+			members, err := teamMemberIDs(context.TODO(), team.ID.Hex())
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load team members"})
+				return
+			}
+			for _, member := range members {
+				if err := policy.CheckSeparation(enforcer, member, *input.Role, orgID, false); err != nil {
+					abortWithViolation(c, err)
+					return
+				}
+			}
+			if team.Role == "admin" {
+				unlock, err := guardAdmins(context.TODO(), orgID, adminChange{team: team.ID.Hex()})
+				if err != nil {
+					respondAdminGuardError(c, err, "Failed to update team")
+					return
+				}
+				defer unlock()
+			}
+			set["role"] = *input.Role
+		}
+		if len(set) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to update"})
+			return
+		}
+
+		err := db.GetTeamCollection().FindOneAndUpdate(
+			context.TODO(),
+			bson.M{"_id": team.ID},
+			bson.M{"$set": set},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&team)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "A team with this name already exists"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update team"})
+			}
+			return
+		}
+
+		if _, changed := set["role"]; changed {
+			for _, role := range enforcer.GetRolesForUserInDomain(subject, orgID) {
+				_, _ = enforcer.DeleteRoleForUserInDomain(subject, role, orgID)
+			}
+			if _, err := enforcer.AddRoleForUserInDomain(subject, team.Role, orgID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update team role"})
+				return
+			}
+		}
+
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.team_updated",
+			ActorID:  user.(models.Identity).ID,
+			TargetID: team.ID.Hex(),
+			Domain:   orgID,
+			Details:  set,
+		})
+		c.JSON(http.StatusOK, team)
+	}
+}
+
+func DeleteTeamHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		team, ok := findTeam(c, c.Query("team_id"))
+		if !ok {
+			return
+		}
+		orgID := team.OrgID
+		subject := teamSubject(team.ID.Hex())
+
+		if team.Role == "admin" {
+			unlock, err := guardAdmins(context.TODO(), orgID, adminChange{team: team.ID.Hex()})
+			if err != nil {
+				respondAdminGuardError(c, err, "Failed to delete team")
+				return
+			}
+			defer unlock()
+		}
+
+		if _, err := db.GetTeamMemberCollection().DeleteMany(context.TODO(), bson.M{"team_id": team.ID.Hex()}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove team members"})
+			return
+		}
+		if _, err := db.GetTeamCollection().DeleteOne(context.TODO(), bson.M{"_id": team.ID}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete team"})
+			return
+		}
+		_, _ = enforcer.RemoveFilteredGroupingPolicy(0, subject, "", orgID)
+		_, _ = enforcer.RemoveFilteredGroupingPolicy(1, subject, orgID)
+
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.team_deleted",
+			ActorID:  user.(models.Identity).ID,
+			TargetID: team.ID.Hex(),
+			Domain:   orgID,
+			Details:  map[string]interface{}{"name": team.Name},
+		})
+		c.JSON(http.StatusOK, gin.H{"message": "Team deleted"})
+	}
+}
+
+func GetTeamMembersHandler(c *gin.Context) {
+	team, ok := findTeam(c, c.Query("team_id"))
+	if !ok {
+		return
+	}
+
+	ids, err := teamMemberIDs(context.TODO(), team.ID.Hex())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve team members"})
+		return
+	}
+	memberships, err := findMemberships(context.TODO(), bson.M{"org_id": team.OrgID, "user_id": bson.M{"$in": ids}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve team members"})
+		return
+	}
+	dir, err := identityDirectory()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load identities"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"team": team, "data": toMembers(memberships, dir)})
+}
+
+// AddTeamMemberHandler adds an existing org member to a team. They pick up
+// the team's role on top of their own.
+func AddTeamMemberHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			TeamID string `json:"team_id" binding:"required"`
+			UserID string `json:"user_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+			return
+		}
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		team, ok := findTeam(c, input.TeamID)
+		if !ok {
+			return
+		}
+		orgID := team.OrgID
+		actorID := user.(models.Identity).ID
+
+		if _, err := getMembership(context.TODO(), orgID, input.UserID); err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusBadRequest, gin.H{"error": errMemberNotFound.Error()})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			}
+			return
+		}
+		if err := policy.CheckSelfElevation(enforcer, actorID, input.UserID, team.Role, orgID); err != nil {
+			abortWithViolation(c, err)
+			return
+		}
+		if err := policy.CheckSeparation(enforcer, input.UserID, team.Role, orgID, false); err != nil {
+			abortWithViolation(c, err)
+			return
+		}
+
+		if err := joinTeam(context.TODO(), enforcer, team, input.UserID, actorID); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				c.JSON(http.StatusConflict, gin.H{"error": "User is already in this team"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add team member"})
+			}
+			return
+		}
+
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.team_member_added",
+			ActorID:  actorID,
+			TargetID: input.UserID,
+			Domain:   orgID,
+			Details:  map[string]interface{}{"team_id": team.ID.Hex(), "team": team.Name},
+		})
+		c.JSON(http.StatusOK, gin.H{"message": "User added to team"})
+	}
+}
+
+func RemoveTeamMemberHandler(enforcer *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			TeamID string `json:"team_id" binding:"required"`
+			UserID string `json:"user_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data"})
+			return
+		}
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found in context"})
+			return
+		}
+		team, ok := findTeam(c, input.TeamID)
+		if !ok {
+			return
+		}
+
+		if team.Role == "admin" {
+			unlock, err := guardAdmins(context.TODO(), team.OrgID, adminChange{team: team.ID.Hex(), teamMember: input.UserID})
+			if err != nil {
+				respondAdminGuardError(c, err, "Failed to remove team member")
+				return
+			}
+			defer unlock()
+		}
+
+		res, err := db.GetTeamMemberCollection().DeleteOne(context.TODO(), bson.M{"team_id": team.ID.Hex(), "user_id": input.UserID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove team member"})
+			return
+		}
+		if res.DeletedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User is not in this team"})
+			return
+		}
+		_, _ = enforcer.DeleteRoleForUserInDomain(input.UserID, teamSubject(team.ID.Hex()), team.OrgID)
+
+		_ = utils.RecordAudit(context.TODO(), models.AuditEntry{
+			Action:   "org.team_member_removed",
+			ActorID:  user.(models.Identity).ID,
+			TargetID: input.UserID,
+			Domain:   team.OrgID,
+			Details:  map[string]interface{}{"team_id": team.ID.Hex(), "team": team.Name},
+		})
+		c.JSON(http.StatusOK, gin.H{"message": "User removed from team"})
+	}
+}
@@ -0,0 +1,109 @@
+// Package logostore keeps uploaded org logos. It writes to the local
+// filesystem or to an S3-compatible bucket, and the store can be swapped,
+// e.g. for an in-memory one in tests.
+package logostore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+)
+
+// ErrNotFound is returned by Get and Delete for an unknown key.
+var ErrNotFound = errors.New("logo not found")
+
+// Store keeps logo images by key. Keys are slash-separated paths made of
+// letters, digits, dots, hyphens and underscores.
+type Store interface {
+	Put(ctx context.Context, key, contentType string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var (
+	mu    sync.RWMutex
+	store Store
+)
+
+// defaultStore uses S3 when LOGO_STORE is "s3" and the local filesystem
+// otherwise.
+func defaultStore() Store {
+	if os.Getenv("LOGO_STORE") == "s3" {
+		return S3StoreFromEnv()
+	}
+	dir := os.Getenv("LOGO_DIR")
+	if dir == "" {
+		dir = "data/logos"
+	}
+	return LocalStore{Dir: dir}
+}
+
+func SetStore(s Store) {
+	mu.Lock()
+	defer mu.Unlock()
+	store = s
+}
+
+// current is resolved on first use, after the environment has been loaded.
+func current() Store {
+	mu.RLock()
+	s := store
+	mu.RUnlock()
+	if s != nil {
+		return s
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if store == nil {
+		store = defaultStore()
+	}
+	return store
+}
+
+func Put(ctx context.Context, key, contentType string, data []byte) error {
+	if !validKey(key) {
+		return errors.New("invalid logo key")
+	}
+	return current().Put(ctx, key, contentType, data)
+}
+
+func Get(ctx context.Context, key string) ([]byte, string, error) {
+	if !validKey(key) {
+		return nil, "", ErrNotFound
+	}
+	return current().Get(ctx, key)
+}
+
+func Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrNotFound
+	}
+	return current().Delete(ctx, key)
+}
+
+func validKey(key string) bool {
+	if key == "" || len(key) > 256 || key[0] == '/' {
+		return false
+	}
+	prev := byte('/')
+	for i := 0; i < len(key); i++ {
+		ch := key[i]
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9', ch == '-', ch == '_':
+		case ch == '.':
+			// No "." or ".." segments.
+			if prev == '/' {
+				return false
+			}
+		case ch == '/':
+			if prev == '/' {
+				return false
+			}
+		default:
+			return false
+		}
+		prev = ch
+	}
+	return prev != '/'
+}
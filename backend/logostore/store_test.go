@@ -0,0 +1,129 @@
+package logostore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// memStore is an in-memory Store that counts the calls reaching it.
+type memStore struct {
+	files map[string][]byte
+	types map[string]string
+	calls int
+}
+
+func newMemStore() *memStore {
+	return &memStore{files: map[string][]byte{}, types: map[string]string{}}
+}
+
+func (m *memStore) Put(ctx context.Context, key, contentType string, data []byte) error {
+	m.calls++
+	m.files[key] = data
+	m.types[key] = contentType
+	return nil
+}
+
+func (m *memStore) Get(ctx context.Context, key string) ([]byte, string, error) {
+	m.calls++
+	data, ok := m.files[key]
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+	return data, m.types[key], nil
+}
+
+func (m *memStore) Delete(ctx context.Context, key string) error {
+	m.calls++
+	if _, ok := m.files[key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.files, key)
+	return nil
+}
+
+func TestValidKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"64b7f0c2a1/3f0e5a52-8c1d-4c47-9d57-1f0b3c2a9e11.png", true},
+		{"logo.png", true},
+		{"org/sub/logo_1-a.webp", true},
+		{"", false},
+		{"/abs.png", false},
+		{"org/", false},
+		{"org//logo.png", false},
+		{"../logo.png", false},
+		{"org/../logo.png", false},
+		{"org/.hidden", false},
+		{"org/logo png", false},
+		{"org\\logo.png", false},
+		{"org/logo.png?x=1", false},
+		{string(bytes.Repeat([]byte("a"), 257)), false},
+	}
+	for _, tt := range tests {
+		if got := validKey(tt.key); got != tt.want {
+			t.Errorf("validKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestSwappedStore(t *testing.T) {
+	mem := newMemStore()
+	SetStore(mem)
+	defer SetStore(nil)
+	ctx := context.Background()
+
+	if err := Put(ctx, "org/logo.png", "image/png", []byte("png")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	data, contentType, err := Get(ctx, "org/logo.png")
+	if err != nil || string(data) != "png" || contentType != "image/png" {
+		t.Fatalf("Get = %q, %q, %v", data, contentType, err)
+	}
+	if err := Delete(ctx, "org/logo.png"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, _, err := Get(ctx, "org/logo.png"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+
+	// Invalid keys are refused before they reach the store.
+	calls := mem.calls
+	if err := Put(ctx, "../escape.png", "image/png", []byte("x")); err == nil {
+		t.Error("Put with an invalid key succeeded")
+	}
+	if _, _, err := Get(ctx, "../escape.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get with an invalid key error = %v, want ErrNotFound", err)
+	}
+	if err := Delete(ctx, "../escape.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete with an invalid key error = %v, want ErrNotFound", err)
+	}
+	if mem.calls != calls {
+		t.Errorf("invalid keys reached the store %d times", mem.calls-calls)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	s := LocalStore{Dir: t.TempDir()}
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "org/logo.png", "image/png", []byte("png")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	data, contentType, err := s.Get(ctx, "org/logo.png")
+	if err != nil || string(data) != "png" || contentType != "image/png" {
+		t.Fatalf("Get = %q, %q, %v", data, contentType, err)
+	}
+	if err := s.Delete(ctx, "org/logo.png"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Delete(ctx, "org/logo.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete error = %v, want ErrNotFound", err)
+	}
+	if _, _, err := s.Get(ctx, "org/missing.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a missing key error = %v, want ErrNotFound", err)
+	}
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"backend/db"
+	"backend/grpcserver"
 	"backend/handler"
 	"backend/middleware"
+	"backend/policy"
+	"context"
 	"log"
+	"os"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -18,7 +22,17 @@ func main() {
 		log.Fatal(err)
 	}
 	defer temporalClient.Close()
-	db.ConnectDB("mongodb://localhost:27017")
+	if err := db.ConnectDB("mongodb://localhost:27017"); err != nil {
+		log.Fatalf("MongoDB init failed: %v", err)
+	}
+	if migrated, err := db.MigrateEmbeddedMembers(context.Background()); err != nil {
+		log.Fatalf("Membership migration failed: %v", err)
+	} else if migrated > 0 {
+		log.Printf("Moved embedded members of %d organizations to memberships", migrated)
+	}
+	if err := db.BackfillAutoJoins(context.Background()); err != nil {
+		log.Fatalf("Auto-join backfill failed: %v", err)
+	}
 	router := gin.Default()
 
 	router.Use(cors.New(cors.Config{
@@ -34,29 +48,126 @@ func main() {
 	if err != nil {
 		log.Fatalf("Casbin init failed: %v", err)
 	}
+	if err := handler.EnsureOrgPolicies(enforcer); err != nil {
+		log.Printf("Warning: failed to backfill org policies: %v", err)
+	}
+	policyDir := os.Getenv("POLICY_DIR")
+	if policyDir == "" {
+		policyDir = "policies"
+	}
+	if err := policy.LoadConstraints(policyDir); err != nil {
+		log.Printf("Warning: failed to load separation-of-duties constraints: %v", err)
+	}
+	// A scheduled policy sync updates the constraints from the worker; pick
+	// them up here as well.
+	go func() {
+		for {
+			if err := policy.RefreshConstraints(context.Background()); err != nil {
+				log.Printf("Warning: failed to refresh separation-of-duties constraints: %v", err)
+			}
+			time.Sleep(time.Minute)
+		}
+	}()
+
+	grpcAddr := os.Getenv("GRPC_ADDR")
+	if grpcAddr == "" {
+		grpcAddr = "127.0.0.1:9090"
+	}
+	go func() {
+		if err := grpcserver.Serve(grpcAddr, enforcer); err != nil {
+			log.Fatalf("gRPC server failed: %v", err)
+		}
+	}()
 
 	router.POST("/logout", handler.Logout)
 	router.POST("/api/register", handler.RegisterHandler(enforcer))
 	router.GET("/auth/oidc/google", handler.OIDCLoginRedirectHandler)
 
+	// Visibility is checked by the handlers, so these also answer anonymous
+	// callers.
+	publicGroup := router.Group("/")
+	publicGroup.Use(middleware.OptionalAuthMiddleware(enforcer))
+	{
+		publicGroup.GET("/orgs/directory", handler.GetOrgDirectoryHandler)
+		publicGroup.GET("/orgs/get/:id", handler.GetOrgByIDHandler)
+		publicGroup.GET("/orgs/logo/:id", handler.GetOrgLogoHandler)
+	}
+
 	authGroup := router.Group("/")
 	authGroup.Use(middleware.AuthorizationMiddleware(enforcer))
 	{
 		authGroup.GET("/home", handler.HomePage)
+		authGroup.GET("/dashboard", handler.DashboardHandler(enforcer))
 		authGroup.GET("/login/github", handler.GitHubLogin)
 		authGroup.GET("/github/callback", handler.GitHubCallback)
 		authGroup.GET("/github/repos", handler.GitHubRepos)
-		authGroup.POST("/github/repos", handler.CreateRepoHandler(temporalClient))
+		authGroup.POST("/github/repos", handler.CreateRepoHandler(temporalClient, enforcer))
 		authGroup.GET("/protected", handler.HomePage)
 		authGroup.GET("/api/admin/identities", handler.GetIdentities(enforcer))
 		authGroup.POST("/api/admin/update-role", handler.UpdateUserRole(enforcer))
+		authGroup.GET("/api/admin/access-matrix", handler.GetAccessMatrix(enforcer))
+		authGroup.GET("/api/admin/analytics", handler.GetAnalyticsHandler)
+		authGroup.POST("/api/break-glass", handler.BreakGlassHandler(temporalClient))
 		authGroup.POST("/orgs/create", handler.CreateOrganizationHandler(enforcer))
 		authGroup.GET("/orgs/get", handler.GetAdminOrgs)
-		authGroup.GET("/orgs/get-all", handler.GetUserOrgs)
-		authGroup.GET("/orgs/get/:id", handler.GetOrgByIDHandler)
+		authGroup.GET("/orgs/get-all", handler.GetUserOrgs(enforcer))
+		authGroup.GET("/orgs/joinable", handler.GetJoinableOrgsHandler)
+		authGroup.POST("/orgs/join", handler.JoinOrgHandler(enforcer))
+		authGroup.POST("/orgs/request-join", handler.RequestToJoinHandler)
+		authGroup.GET("/orgs/join-requests", handler.GetMyJoinRequestsHandler)
+		authGroup.POST("/orgs/join-requests/cancel", handler.CancelJoinRequestHandler)
+		authGroup.GET("/orgs/members/:id", handler.GetOrgMembersHandler)
+		authGroup.GET("/orgs/members/inactive/:id", handler.GetInactiveMembersHandler)
+		authGroup.GET("/orgs/export/:id", handler.ExportOrgHandler(enforcer))
+		authGroup.POST("/orgs/import-archive", handler.ImportOrgHandler(enforcer, temporalClient))
+		authGroup.GET("/orgs/settings/:id", handler.GetOrgSettingsHandler)
+		authGroup.PUT("/orgs/settings/:id", handler.UpdateOrgSettingsHandler)
+		authGroup.POST("/orgs/settings/logo/:id", handler.UploadOrgLogoHandler)
+		authGroup.DELETE("/orgs/settings/logo/:id", handler.DeleteOrgLogoHandler)
 		authGroup.POST("/orgs/invite/:id", handler.InviteUserHandler(temporalClient))
 		authGroup.GET("/orgs/accept/:id", handler.AcceptInviteHandler(enforcer))
 		authGroup.POST("/orgs/update-role/:id", handler.UpdateUserRoleInOrgHandler(enforcer))
+		authGroup.PUT("/orgs/update/:id", handler.UpdateOrganizationHandler)
+		authGroup.DELETE("/orgs/delete/:id", handler.DeleteOrganizationHandler(temporalClient))
+		authGroup.POST("/orgs/restore/:id", handler.RestoreOrganizationHandler(enforcer, temporalClient))
+		authGroup.POST("/orgs/remove-member/:id", handler.RemoveMemberHandler(enforcer))
+		authGroup.POST("/orgs/leave/:id", handler.LeaveOrgHandler(enforcer))
+		authGroup.POST("/orgs/transfer-ownership/:id", handler.TransferOwnershipHandler)
+		authGroup.POST("/orgs/accept-ownership/:id", handler.AcceptOwnershipHandler(enforcer))
+		authGroup.POST("/orgs/decline-ownership/:id", handler.DeclineOwnershipHandler)
+		authGroup.GET("/orgs/audit/:id", handler.GetOrgAuditHandler)
+		authGroup.GET("/orgs/teams/:id", handler.GetTeamsHandler)
+		authGroup.GET("/orgs/teams/members/:id", handler.GetTeamMembersHandler)
+		authGroup.POST("/orgs/teams/create/:id", handler.CreateTeamHandler(enforcer))
+		authGroup.PUT("/orgs/teams/update/:id", handler.UpdateTeamHandler(enforcer))
+		authGroup.DELETE("/orgs/teams/delete/:id", handler.DeleteTeamHandler(enforcer))
+		authGroup.POST("/orgs/teams/add-member/:id", handler.AddTeamMemberHandler(enforcer))
+		authGroup.POST("/orgs/teams/remove-member/:id", handler.RemoveTeamMemberHandler(enforcer))
+		authGroup.GET("/orgs/projects/:id", handler.GetProjectsHandler(enforcer))
+		authGroup.GET("/orgs/projects/get/:id", handler.GetProjectHandler(enforcer))
+		authGroup.POST("/orgs/projects/create/:id", handler.CreateProjectHandler(enforcer))
+		authGroup.PUT("/orgs/projects/update/:id", handler.UpdateProjectHandler(enforcer))
+		authGroup.DELETE("/orgs/projects/delete/:id", handler.DeleteProjectHandler(enforcer))
+		authGroup.GET("/orgs/projects/members/:id", handler.GetProjectMembersHandler(enforcer))
+		authGroup.POST("/orgs/projects/add-member/:id", handler.AddProjectMemberHandler(enforcer))
+		authGroup.POST("/orgs/projects/remove-member/:id", handler.RemoveProjectMemberHandler(enforcer))
+		authGroup.GET("/orgs/join-requests/:id", handler.GetJoinRequestsHandler)
+		authGroup.POST("/orgs/join-requests/approve/:id", handler.ApproveJoinRequestHandler(enforcer))
+		authGroup.POST("/orgs/join-requests/deny/:id", handler.DenyJoinRequestHandler)
+		authGroup.GET("/orgs/secrets/:id", handler.GetSecretsHandler)
+		authGroup.GET("/orgs/secrets/versions/:id", handler.GetSecretVersionsHandler)
+		authGroup.GET("/orgs/secrets/read/:id", handler.ReadSecretHandler)
+		authGroup.POST("/orgs/secrets/put/:id", handler.PutSecretHandler)
+		authGroup.DELETE("/orgs/secrets/delete/:id", handler.DeleteSecretHandler)
+		authGroup.GET("/orgs/usage/:id", handler.GetOrgUsageHandler)
+		authGroup.POST("/orgs/import/:id", handler.ImportMembersHandler(temporalClient))
+		authGroup.GET("/orgs/import/status/:id", handler.GetImportStatusHandler(temporalClient))
+		authGroup.GET("/orgs/import/errors/:id", handler.GetImportErrorsHandler(temporalClient))
+		authGroup.POST("/api/admin/org-plan", handler.UpdateOrgPlanHandler)
+		authGroup.GET("/orgs/domains/:id", handler.GetDomainsHandler)
+		authGroup.POST("/orgs/domains/claim/:id", handler.ClaimDomainHandler)
+		authGroup.POST("/orgs/domains/verify/:id", handler.VerifyDomainHandler)
+		authGroup.DELETE("/orgs/domains/delete/:id", handler.DeleteDomainHandler)
 	}
 
 	router.Run(":8080")
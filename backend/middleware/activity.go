@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"backend/db"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// lastSeenInterval is how often a user's activity is written per domain.
+// Requests in between only touch the in-memory throttle.
+const lastSeenInterval = 5 * time.Minute
+
+var (
+	lastSeenMu      sync.Mutex
+	lastSeenWritten = map[string]time.Time{}
+	lastSeenSwept   time.Time
+)
+
+// recordLastSeen notes that userID was active in dom ("main" for global
+// activity). The write happens in the background and at most once per
+// lastSeenInterval for each user and domain.
+func recordLastSeen(userID, dom string) {
+	now := time.Now()
+	key := userID + "|" + dom
+
+	lastSeenMu.Lock()
+	// Entries older than the interval no longer throttle anything; drop them
+	// once per interval so the map only holds recently active users.
+	if now.Sub(lastSeenSwept) >= lastSeenInterval {
+		for k, written := range lastSeenWritten {
+			if now.Sub(written) >= lastSeenInterval {
+				delete(lastSeenWritten, k)
+			}
+		}
+		lastSeenSwept = now
+	}
+	if now.Sub(lastSeenWritten[key]) < lastSeenInterval {
+		lastSeenMu.Unlock()
+		return
+	}
+	lastSeenWritten[key] = now
+	lastSeenMu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := db.GetLastSeenCollection().UpdateOne(ctx,
+			bson.M{"user_id": userID, "domain": dom},
+			bson.M{"$max": bson.M{"seen_at": now}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			fmt.Printf("Warning: failed to record last seen for %s: %v\n", userID, err)
+			// Let the next request try again.
+			lastSeenMu.Lock()
+			delete(lastSeenWritten, key)
+			lastSeenMu.Unlock()
+		}
+	}()
+}
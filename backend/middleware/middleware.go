@@ -1,11 +1,16 @@
 package middleware
 
 import (
+	"backend/db"
 	"backend/models"
+	"backend/policy"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/casbin/casbin/v2"
 	mongodbadapter "github.com/casbin/mongodb-adapter/v3"
@@ -26,74 +31,34 @@ func InitCasbin() (*casbin.Enforcer, error) {
 	if err := enforcer.LoadPolicy(); err != nil {
 		return nil, err
 	}
-	// seedPolicy(enforcer)
+	// Base roles and global policies live in policies/*.yaml and are applied
+	// with cmd/policy-sync.
 	return enforcer, nil
 }
 
-// func seedPolicy(e *casbin.Enforcer) {
-
-// 	_, _ = e.AddPolicy("reader", "main", "/home", "GET")
-// 	_, _ = e.AddPolicy("reader", "main", "/login/github", "GET")
-// 	_, _ = e.AddPolicy("reader", "main", "/github/callback", "GET")
-// 	_, _ = e.AddPolicy("admin", "main", "/api/admin/update-role", "POST")
-// 	_, _ = e.AddPolicy("admin", "main", "/api/admin/identities", "GET")
-// 	_, _ = e.AddPolicy("admin", "main", "/protected", "GET")
-// 	_, _ = e.AddPolicy("writer", "main", "/github/repos", "POST")
-// 	_, _ = e.AddPolicy("reader", "main", "/github/repos", "GET")
-// 	_, _ = e.AddPolicy("reader", "main", "/orgs/create", "POST")
-// 	_, _ = e.AddPolicy("reader", "main", "/orgs/get", "GET")
-// 	_, _ = e.AddPolicy("reader", "main", "/orgs/get-all", "GET")
-
-// 	// Role hierarchy
-// 	_, _ = e.AddGroupingPolicy("admin", "writer", "main")
-// 	_, _ = e.AddGroupingPolicy("writer", "reader", "main")
-
-// // Map users (UUIDs from Kratos) to roles
-// _, _ = e.AddGroupingPolicy("5a833c71-e6e8-4388-9c7c-39ac8a00055d", "admin", "main")
-// _, _ = e.AddGroupingPolicy("6f652339-2ee8-4330-8a0f-47bd3214bea9", "reader", "main")
-// _, _ = e.AddGroupingPolicy("2162fe0d-dd24-4530-80ae-ee6d9baadf50", "writer", "main")
-
-//		_ = e.SavePolicy()
-//	}
 func AuthorizationMiddleware(e *casbin.Enforcer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		_ = e.LoadPolicy()
-		cookie, err := c.Request.Cookie("ory_kratos_session")
+		identity, status, err := fetchSession(c)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing session cookie"})
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
 			return
 		}
 
-		req, err := http.NewRequest("GET", "http://localhost:4433/sessions/whoami", nil)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
-			return
-		}
-		req.Header.Set("Cookie", "ory_kratos_session="+cookie.Value)
-
-		client := &http.Client{}
-		res, err := client.Do(req)
-		if err != nil || res.StatusCode != 200 {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired session"})
-			return
-		}
-		defer res.Body.Close()
-
-		var session struct {
-			Identity models.Identity `json:"identity"`
-		}
-		if err := json.NewDecoder(res.Body).Decode(&session); err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode session"})
-			return
-		}
-
-		user := session.Identity.ID
+		user := identity.ID
 		obj := c.Request.URL.Path
 		act := c.Request.Method
 		dom := c.Param("id")
 		if dom == "" {
 			dom = "main"
+		} else if orgID, err := resolveOrgParam(c); err != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		} else if orgID != dom {
+			// Policies are keyed by org ID, so check the ID form of the path.
+			obj = strings.TrimSuffix(obj, "/"+dom) + "/" + orgID
+			dom = orgID
 		}
 		roles := e.GetRolesForUserInDomain(user, "main")
 		role := ""
@@ -102,7 +67,7 @@ func AuthorizationMiddleware(e *casbin.Enforcer) gin.HandlerFunc {
 		} else {
 			hasGroup, _ := e.GetRoleManager().HasLink(user, "reader", "main")
 			if !hasGroup {
-				_, err = e.AddGroupingPolicy(user, "reader", "main")
+				_, err = policy.AddGroupingPolicy(e, user, "reader", "main")
 				if err != nil {
 					fmt.Println("failed to assign role:", err)
 					return
@@ -126,8 +91,97 @@ func AuthorizationMiddleware(e *casbin.Enforcer) gin.HandlerFunc {
 			return
 		}
 
-		c.Set("user", session.Identity)
+		recordLastSeen(user, "main")
+		if dom != "main" {
+			recordLastSeen(user, dom)
+		}
+
+		c.Set("user", identity)
 		c.Set("role", role)
+		if dom != "main" {
+			// Implicit roles follow g links, so roles granted to the user's
+			// teams are included.
+			orgRoles, _ := e.GetImplicitRolesForUser(user, dom)
+			c.Set("org_roles", orgRoles)
+		}
 		c.Next()
 	}
 }
+
+// fetchSession asks Kratos for the identity behind the session cookie. On
+// failure it also returns the status to answer with.
+func fetchSession(c *gin.Context) (models.Identity, int, error) {
+	cookie, err := c.Request.Cookie("ory_kratos_session")
+	if err != nil {
+		return models.Identity{}, http.StatusUnauthorized, errors.New("Missing session cookie")
+	}
+
+	req, err := http.NewRequest("GET", "http://localhost:4433/sessions/whoami", nil)
+	if err != nil {
+		return models.Identity{}, http.StatusInternalServerError, errors.New("Failed to create request")
+	}
+	req.Header.Set("Cookie", "ory_kratos_session="+cookie.Value)
+
+	client := &http.Client{}
+	res, err := client.Do(req)
+	if err != nil {
+		return models.Identity{}, http.StatusUnauthorized, errors.New("Invalid or expired session")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return models.Identity{}, http.StatusUnauthorized, errors.New("Invalid or expired session")
+	}
+
+	var session struct {
+		Identity models.Identity `json:"identity"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&session); err != nil {
+		return models.Identity{}, http.StatusInternalServerError, errors.New("Failed to decode session")
+	}
+	return session.Identity, http.StatusOK, nil
+}
+
+// OptionalAuthMiddleware is for routes that anonymous callers may use too.
+// A valid session sets "user" (and "org_roles" on org routes) like
+// AuthorizationMiddleware does, but no policy is enforced: the handlers
+// decide what the caller may see.
+func OptionalAuthMiddleware(e *casbin.Enforcer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID := ""
+		if c.Param("id") != "" {
+			id, err := resolveOrgParam(c)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+				return
+			}
+			orgID = id
+		}
+
+		identity, _, err := fetchSession(c)
+		if err == nil {
+			recordLastSeen(identity.ID, "main")
+			c.Set("user", identity)
+			if orgID != "" {
+				_ = e.LoadPolicy()
+				orgRoles, _ := e.GetImplicitRolesForUser(identity.ID, orgID)
+				c.Set("org_roles", orgRoles)
+			}
+		}
+		c.Next()
+	}
+}
+
+// resolveOrgParam lets org routes take a slug in place of the org ID. The
+// :id param is rewritten to the ID so handlers only ever see IDs.
+func resolveOrgParam(c *gin.Context) (string, error) {
+	orgID, err := db.ResolveOrgID(context.TODO(), c.Param("id"))
+	if err != nil {
+		return "", err
+	}
+	for i := range c.Params {
+		if c.Params[i].Key == "id" {
+			c.Params[i].Value = orgID
+		}
+	}
+	return orgID, nil
+}
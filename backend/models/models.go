@@ -12,14 +12,76 @@ type Identity struct {
 		Email string `json:"email"`
 		Name  string `json:"name"`
 	} `json:"traits"`
+	VerifiableAddresses []VerifiableAddress `json:"verifiable_addresses,omitempty"`
+	CreatedAt           time.Time           `json:"created_at,omitempty"`
+}
+type VerifiableAddress struct {
+	Value    string `json:"value"`
+	Verified bool   `json:"verified"`
+	Via      string `json:"via"`
 }
 type Organization struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name        string             `bson:"name" json:"name"`
+	Slug        string             `bson:"slug" json:"slug"`
 	Description string             `bson:"description" json:"description"`
 	CreatedBy   string             `bson:"created_by" json:"created_by"`
 	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
-	Users       []User             `bson:"users"`
+	Plan        string             `bson:"plan,omitempty" json:"plan,omitempty"`
+
+	// Visibility is private, internal or public. Orgs without one are private.
+	Visibility string `bson:"visibility,omitempty" json:"visibility,omitempty"`
+
+	// Set while the org is soft-deleted and waiting to be purged.
+	ArchivedAt     *time.Time `bson:"archived_at,omitempty" json:"archived_at,omitempty"`
+	ArchivedBy     string     `bson:"archived_by,omitempty" json:"archived_by,omitempty"`
+	PurgeAt        *time.Time `bson:"purge_at,omitempty" json:"purge_at,omitempty"`
+	FrozenPolicies [][]string `bson:"frozen_policies,omitempty" json:"-"`
+
+	PendingTransfer *OwnershipTransfer `bson:"pending_transfer,omitempty" json:"pending_transfer,omitempty"`
+
+	// Held while a member is being removed; see lockOrgAdmins.
+	AdminLock *time.Time `bson:"admin_lock,omitempty" json:"-"`
+
+	Settings *OrgSettings `bson:"settings,omitempty" json:"settings,omitempty"`
+}
+
+// OrgSettings is the org's settings sub-document. SchemaVersion records the
+// layout it was written with; see orgsettings.Upgrade.
+type OrgSettings struct {
+	SchemaVersion int         `bson:"schema_version" json:"schema_version"`
+	Branding      OrgBranding `bson:"branding" json:"branding"`
+	LandingPage   string      `bson:"landing_page" json:"landing_page"`
+	ContactEmail  string      `bson:"contact_email,omitempty" json:"contact_email,omitempty"`
+	UpdatedBy     string      `bson:"updated_by,omitempty" json:"updated_by,omitempty"`
+	UpdatedAt     *time.Time  `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// OrgBranding holds either an external LogoURL or an uploaded logo, which is
+// kept in the logo store under LogoKey.
+type OrgBranding struct {
+	LogoURL         string `bson:"logo_url,omitempty" json:"logo_url,omitempty"`
+	LogoKey         string `bson:"logo_key,omitempty" json:"-"`
+	LogoContentType string `bson:"logo_content_type,omitempty" json:"-"`
+	PrimaryColor    string `bson:"primary_color,omitempty" json:"primary_color,omitempty"`
+	AccentColor     string `bson:"accent_color,omitempty" json:"accent_color,omitempty"`
+}
+
+// DirectoryOrg is what non-members get to see of an org.
+type DirectoryOrg struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	Slug        string             `bson:"slug" json:"slug"`
+	Description string             `bson:"description" json:"description"`
+	Visibility  string             `bson:"visibility" json:"visibility"`
+	MemberCount int64              `bson:"member_count" json:"member_count"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type OwnershipTransfer struct {
+	From        string    `bson:"from" json:"from"`
+	To          string    `bson:"to" json:"to"`
+	RequestedAt time.Time `bson:"requested_at" json:"requested_at"`
 }
 type User struct {
 	ID    string `bson:"_id,omitempty" json:"id"`
@@ -27,12 +89,194 @@ type User struct {
 	Name  string `bson:"name" json:"name"`
 	Role  string `bson:"role" json:"role"`
 }
+type Membership struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	OrgID     string             `bson:"org_id" json:"org_id"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	Role      string             `bson:"role" json:"role"`
+	JoinedAt  time.Time          `bson:"joined_at" json:"joined_at"`
+	InvitedBy string             `bson:"invited_by,omitempty" json:"invited_by,omitempty"`
+}
+
+// Member is a membership joined with the member's Kratos traits, as returned
+// by the member listing.
+type Member struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	JoinedAt  time.Time `json:"joined_at"`
+	InvitedBy string    `json:"invited_by,omitempty"`
+	// LastSeenAt is the member's last request in the org, when known.
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// LastSeen is when a user was last active, globally (domain "main") or in
+// one org domain.
+type LastSeen struct {
+	UserID string    `bson:"user_id" json:"user_id"`
+	Domain string    `bson:"domain" json:"domain"`
+	SeenAt time.Time `bson:"seen_at" json:"seen_at"`
+}
+type Invite struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrgID      string             `bson:"org_id" json:"org_id"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	Email      string             `bson:"email" json:"email"`
+	Role       string             `bson:"role" json:"role"`
+	InvitedBy  string             `bson:"invited_by" json:"invited_by"`
+	TeamID     string             `bson:"team_id,omitempty" json:"team_id,omitempty"`
+	Status     string             `bson:"status" json:"status"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	AcceptedAt *time.Time         `bson:"accepted_at,omitempty" json:"accepted_at,omitempty"`
+}
+
+// JoinRequest is a user's request to join an org, reviewed by its admins.
+// Status is one of pending, approved, denied or cancelled.
+type JoinRequest struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrgID      string             `bson:"org_id" json:"org_id"`
+	OrgName    string             `bson:"org_name" json:"org_name"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	Email      string             `bson:"email" json:"email"`
+	Name       string             `bson:"name" json:"name"`
+	Message    string             `bson:"message,omitempty" json:"message,omitempty"`
+	Status     string             `bson:"status" json:"status"`
+	Role       string             `bson:"role,omitempty" json:"role,omitempty"`
+	ReviewedBy string             `bson:"reviewed_by,omitempty" json:"reviewed_by,omitempty"`
+	ReviewNote string             `bson:"review_note,omitempty" json:"review_note,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	ReviewedAt *time.Time         `bson:"reviewed_at,omitempty" json:"reviewed_at,omitempty"`
+}
+
+// Team groups org members so a role can be granted to all of them at once.
+// In Casbin the team is the subject "team:<id>".
+type Team struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrgID       string             `bson:"org_id" json:"org_id"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
+	Role        string             `bson:"role" json:"role"`
+	CreatedBy   string             `bson:"created_by" json:"created_by"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Project is a resource inside an org with its own members. Its roles are
+// Casbin subjects "project:<id>:<role>" in the org domain.
+type Project struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrgID       string             `bson:"org_id" json:"org_id"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
+	CreatedBy   string             `bson:"created_by" json:"created_by"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type ProjectMember struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ProjectID string             `bson:"project_id" json:"project_id"`
+	OrgID     string             `bson:"org_id" json:"org_id"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	Role      string             `bson:"role" json:"role"`
+	AddedBy   string             `bson:"added_by" json:"added_by"`
+	AddedAt   time.Time          `bson:"added_at" json:"added_at"`
+}
+
+type TeamMember struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	TeamID  string             `bson:"team_id" json:"team_id"`
+	OrgID   string             `bson:"org_id" json:"org_id"`
+	UserID  string             `bson:"user_id" json:"user_id"`
+	AddedBy string             `bson:"added_by" json:"added_by"`
+	AddedAt time.Time          `bson:"added_at" json:"added_at"`
+}
+
+// DomainClaim is an org's claim on an email domain. Once verified through
+// DNS, users with a verified address on the domain can join with
+// DefaultRole, or are added automatically when AutoJoin is set.
+type DomainClaim struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrgID       string             `bson:"org_id" json:"org_id"`
+	Domain      string             `bson:"domain" json:"domain"`
+	Token       string             `bson:"token" json:"-"`
+	DefaultRole string             `bson:"default_role" json:"default_role"`
+	AutoJoin    bool               `bson:"auto_join" json:"auto_join"`
+	Verified    bool               `bson:"verified" json:"verified"`
+	VerifiedAt  *time.Time         `bson:"verified_at,omitempty" json:"verified_at,omitempty"`
+	CreatedBy   string             `bson:"created_by" json:"created_by"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// AutoJoin records that a user joined an org through a domain claim, or was
+// removed from it by an admin. Each user is auto-joined to an org at most
+// once, so leaving or being removed sticks, and a removed user can't rejoin
+// through the domain until they are invited back.
+type AutoJoin struct {
+	OrgID     string     `bson:"org_id" json:"org_id"`
+	UserID    string     `bson:"user_id" json:"user_id"`
+	Domain    string     `bson:"domain,omitempty" json:"domain,omitempty"`
+	JoinedAt  time.Time  `bson:"joined_at,omitempty" json:"joined_at,omitempty"`
+	RemovedAt *time.Time `bson:"removed_at,omitempty" json:"removed_at,omitempty"`
+	RemovedBy string     `bson:"removed_by,omitempty" json:"removed_by,omitempty"`
+}
 
 type CreateRepoInput struct {
 	Name        string
 	Description string
 	Private     bool
 	GithubToken string
+	OrgID       string
+	ProjectID   string
+	CreatedBy   string
+}
+
+// Repo records a repository created for an org.
+type Repo struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrgID     string             `bson:"org_id" json:"org_id"`
+	ProjectID string             `bson:"project_id,omitempty" json:"project_id,omitempty"`
+	Name      string             `bson:"name" json:"name"`
+	FullName  string             `bson:"full_name" json:"full_name"`
+	URL       string             `bson:"url" json:"url"`
+	Private   bool               `bson:"private" json:"private"`
+	CreatedBy string             `bson:"created_by" json:"created_by"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// OrgDataKey is an org's secret-encryption key, wrapped with the master key.
+type OrgDataKey struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrgID       string             `bson:"org_id" json:"org_id"`
+	WrappedKey  []byte             `bson:"wrapped_key" json:"-"`
+	MasterKeyID string             `bson:"master_key_id" json:"master_key_id"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Secret is one version of an org secret. The value is only ever stored
+// encrypted with the org's data key.
+type Secret struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OrgID      string             `bson:"org_id" json:"org_id"`
+	Name       string             `bson:"name" json:"name"`
+	Version    int                `bson:"version" json:"version"`
+	Ciphertext []byte             `bson:"ciphertext" json:"-"`
+	ReadRoles  []string           `bson:"read_roles" json:"read_roles"`
+	CreatedBy  string             `bson:"created_by" json:"created_by"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// ReadSecretInput asks an activity for a secret value. Version 0 is the
+// latest version.
+type ReadSecretInput struct {
+	OrgID   string
+	Name    string
+	Version int
+}
+
+// OrgLimitCheck asks a workflow to re-check a plan limit before acting.
+type OrgLimitCheck struct {
+	OrgID string
+	Limit string
 }
 type InviteRequest struct {
 	OrgID       string `json:"org_id"`
@@ -46,6 +290,8 @@ type CreateInvite struct {
 	Email       string
 	Description string
 	UserId      string
+	Role        string
+	TeamID      string
 }
 type IdentetyEmail struct {
 	Email      string
@@ -59,3 +305,81 @@ type AddCasbinPolicy struct {
 	UserId string
 	OrgId  string
 }
+type PolicySyncInput struct {
+	Dir       string
+	ModelPath string
+	Pull      bool
+	DryRun    bool
+}
+type PolicySyncResult struct {
+	Revision string
+	DryRun   bool
+	Added    [][]string
+	Removed  [][]string
+}
+type AuditEntry struct {
+	ID        primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	Action    string                 `bson:"action" json:"action"`
+	ActorID   string                 `bson:"actor_id" json:"actor_id"`
+	TargetID  string                 `bson:"target_id,omitempty" json:"target_id,omitempty"`
+	Domain    string                 `bson:"domain,omitempty" json:"domain,omitempty"`
+	Details   map[string]interface{} `bson:"details,omitempty" json:"details,omitempty"`
+	CreatedAt time.Time              `bson:"created_at" json:"created_at"`
+}
+type BreakGlassInput struct {
+	UserId   string
+	Email    string
+	Reason   string
+	Duration time.Duration
+}
+type DeleteOrgInput struct {
+	OrgId           string
+	OrgName         string
+	RequestedBy     string
+	Members         []Member
+	RetentionWindow time.Duration
+	// Resumed is set when delete is retried for an org that is already
+	// archived, so members aren't told about the archive twice.
+	Resumed bool
+}
+
+type RestoreOrgSignal struct {
+	RequestedBy string
+}
+
+// BulkImportRow is one validated line of a member import CSV. Row is the
+// line number in the file.
+type BulkImportRow struct {
+	Row    int    `json:"row"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+	Team   string `json:"team,omitempty"`
+	TeamID string `json:"team_id,omitempty"`
+}
+
+type BulkImportInput struct {
+	ImportID    string
+	OrgID       string
+	OrgName     string
+	Description string
+	RequestedBy string
+	Rows        []BulkImportRow
+}
+
+type BulkImportRowResult struct {
+	BulkImportRow
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkImportProgress is both the import workflow's query result and its
+// final result.
+type BulkImportProgress struct {
+	ImportID  string                `json:"import_id"`
+	Total     int                   `json:"total"`
+	Processed int                   `json:"processed"`
+	Succeeded int                   `json:"succeeded"`
+	Failed    int                   `json:"failed"`
+	Done      bool                  `json:"done"`
+	Results   []BulkImportRowResult `json:"results"`
+}
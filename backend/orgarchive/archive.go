@@ -0,0 +1,204 @@
+// Package orgarchive reads and writes the zip archive an org is exported to.
+// Users are referred to by email, and the org ID in rule paths is replaced by
+// OrgPlaceholder, so an archive can be imported into another deployment.
+package orgarchive
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// FormatVersion is bumped whenever the archive layout changes incompatibly.
+const FormatVersion = 1
+
+// OrgPlaceholder stands for the org ID inside exported rules.
+const OrgPlaceholder = "{org}"
+
+// UserPrefix marks a rule subject that is a user, given by email.
+const UserPrefix = "user:"
+
+// logoFile holds an uploaded logo's bytes, next to the JSON files.
+const logoFile = "logo"
+
+// MaxSize caps the archive size accepted on import.
+const MaxSize = 10 << 20
+
+type Manifest struct {
+	FormatVersion int       `json:"format_version"`
+	SourceOrgID   string    `json:"source_org_id"`
+	ExportedBy    string    `json:"exported_by"`
+	ExportedAt    time.Time `json:"exported_at"`
+}
+
+type Org struct {
+	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Settings struct {
+	Visibility string        `json:"visibility"`
+	Plan       string        `json:"plan"`
+	Domains    []DomainClaim `json:"domains"`
+	Branding   *Branding     `json:"branding,omitempty"`
+}
+
+// Branding is the org's settings document. An uploaded logo travels as
+// Archive.Logo with LogoContentType set; a linked logo as LogoURL.
+type Branding struct {
+	SchemaVersion   int    `json:"schema_version"`
+	LogoURL         string `json:"logo_url,omitempty"`
+	LogoContentType string `json:"logo_content_type,omitempty"`
+	PrimaryColor    string `json:"primary_color,omitempty"`
+	AccentColor     string `json:"accent_color,omitempty"`
+	LandingPage     string `json:"landing_page,omitempty"`
+	ContactEmail    string `json:"contact_email,omitempty"`
+}
+
+// DomainClaim is exported without its verification; it has to be verified
+// again after import.
+type DomainClaim struct {
+	Domain      string `json:"domain"`
+	DefaultRole string `json:"default_role"`
+	AutoJoin    bool   `json:"auto_join"`
+}
+
+type Member struct {
+	Email    string    `json:"email"`
+	Name     string    `json:"name"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// Team keeps its exported ID as Key so rules naming "team:<key>" can be
+// remapped.
+type Team struct {
+	Key         string   `json:"key"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Role        string   `json:"role"`
+	Members     []string `json:"members"`
+}
+
+type Project struct {
+	Key         string          `json:"key"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Members     []ProjectMember `json:"members"`
+}
+
+type ProjectMember struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// Policies are the org domain's Casbin rules without the domain column:
+// p rules as [sub, obj, act] and g rules as [sub, role].
+type Policies struct {
+	P [][]string `json:"p"`
+	G [][]string `json:"g"`
+}
+
+type Archive struct {
+	Manifest Manifest
+	Org      Org
+	Settings Settings
+	Members  []Member
+	Teams    []Team
+	Projects []Project
+	Policies Policies
+	Logo     []byte
+}
+
+// files maps each archive entry to the value it holds.
+func (a *Archive) files() []struct {
+	name  string
+	value interface{}
+} {
+	return []struct {
+		name  string
+		value interface{}
+	}{
+		{"manifest.json", &a.Manifest},
+		{"org.json", &a.Org},
+		{"settings.json", &a.Settings},
+		{"members.json", &a.Members},
+		{"teams.json", &a.Teams},
+		{"projects.json", &a.Projects},
+		{"policies.json", &a.Policies},
+	}
+}
+
+func Write(w io.Writer, a *Archive) error {
+	zw := zip.NewWriter(w)
+	for _, f := range a.files() {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.value); err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.name, err)
+		}
+	}
+	if len(a.Logo) > 0 {
+		fw, err := zw.Create(logoFile)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(a.Logo); err != nil {
+			return fmt.Errorf("failed to write %s: %w", logoFile, err)
+		}
+	}
+	return zw.Close()
+}
+
+func Read(r io.ReaderAt, size int64) (*Archive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.New("not a zip archive")
+	}
+	entries := map[string]*zip.File{}
+	for _, f := range zr.File {
+		entries[f.Name] = f
+	}
+
+	a := &Archive{}
+	for _, f := range a.files() {
+		entry, ok := entries[f.name]
+		if !ok {
+			return nil, fmt.Errorf("archive is missing %s", f.name)
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		err = json.NewDecoder(io.LimitReader(rc, MaxSize)).Decode(f.value)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", f.name, err)
+		}
+	}
+	if a.Manifest.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("unsupported archive format version %d", a.Manifest.FormatVersion)
+	}
+	// The logo is optional; archives of orgs without one don't have it.
+	if entry, ok := entries[logoFile]; ok {
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		a.Logo, err = io.ReadAll(io.LimitReader(rc, MaxSize))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", logoFile, err)
+		}
+	}
+	return a, nil
+}
@@ -0,0 +1,116 @@
+package orgarchive
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleArchive() *Archive {
+	exported := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	return &Archive{
+		Manifest: Manifest{FormatVersion: FormatVersion, SourceOrgID: "64b7f0c2a1b2c3d4e5f60718", ExportedBy: "owner@example.com", ExportedAt: exported},
+		Org:      Org{Name: "Acme", Slug: "acme", Description: "Widgets", CreatedAt: exported.AddDate(-1, 0, 0)},
+		Settings: Settings{
+			Visibility: "internal",
+			Plan:       "team",
+			Domains:    []DomainClaim{{Domain: "acme.test", DefaultRole: "reader", AutoJoin: true}},
+			Branding:   &Branding{SchemaVersion: 1, LogoContentType: "image/png", PrimaryColor: "#112233", LandingPage: "repos"},
+		},
+		Members:  []Member{{Email: "owner@example.com", Name: "Owner", Role: "admin", JoinedAt: exported}},
+		Teams:    []Team{{Key: "t1", Name: "Core", Role: "writer", Members: []string{"owner@example.com"}}},
+		Projects: []Project{{Key: "p1", Name: "Site", Members: []ProjectMember{{Email: "owner@example.com", Role: "admin"}}}},
+		Policies: Policies{
+			P: [][]string{{"billing", "/orgs/usage/" + OrgPlaceholder, "GET"}},
+			G: [][]string{{UserPrefix + "owner@example.com", "admin"}},
+		},
+		Logo: []byte("\x89PNG\r\n\x1a\n"),
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Archive)
+	}{
+		{"full", func(*Archive) {}},
+		{"without logo or branding", func(a *Archive) {
+			a.Logo = nil
+			a.Settings.Branding = nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := sampleArchive()
+			tt.modify(want)
+
+			var buf bytes.Buffer
+			if err := Write(&buf, want); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			got, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip mismatch:\n got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	// withEntries writes a zip holding the named entries of a valid archive,
+	// with the manifest replaced by manifest when it is set.
+	withEntries := func(skip, manifest string) []byte {
+		var full bytes.Buffer
+		if err := Write(&full, sampleArchive()); err != nil {
+			t.Fatal(err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(full.Bytes()), int64(full.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		zw := zip.NewWriter(&out)
+		for _, f := range zr.File {
+			if f.Name == skip {
+				continue
+			}
+			w, _ := zw.Create(f.Name)
+			if f.Name == "manifest.json" && manifest != "" {
+				w.Write([]byte(manifest))
+				continue
+			}
+			rc, _ := f.Open()
+			var data bytes.Buffer
+			data.ReadFrom(rc)
+			rc.Close()
+			w.Write(data.Bytes())
+		}
+		zw.Close()
+		return out.Bytes()
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"not a zip", []byte("plain text"), "not a zip archive"},
+		{"missing file", withEntries("teams.json", ""), "missing teams.json"},
+		{"invalid json", withEntries("", "{"), "invalid manifest.json"},
+		{"newer format", withEntries("", `{"format_version": 99}`), "unsupported archive format version 99"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Read(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Read() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
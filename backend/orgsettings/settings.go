@@ -0,0 +1,140 @@
+// Package orgsettings validates an org's settings sub-document and upgrades
+// documents written with an older schema.
+package orgsettings
+
+import (
+	"backend/models"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// SchemaVersion is the layout current code writes. Bump it, and teach Upgrade
+// the step, whenever a field changes meaning or moves.
+const SchemaVersion = 1
+
+// LandingPages are the org pages members can be sent to after signing in.
+var LandingPages = []string{"overview", "members", "teams", "projects", "repos"}
+
+const DefaultLandingPage = "overview"
+
+var colorPattern = regexp.MustCompile(`^#([0-9a-f]{3}|[0-9a-f]{6})$`)
+
+// ErrNewerSchema means the document was written by a newer version of the
+// server and must not be overwritten with an older layout.
+var ErrNewerSchema = errors.New("settings were saved with a newer schema version")
+
+// Defaults are the settings of an org that never saved any.
+func Defaults() models.OrgSettings {
+	return models.OrgSettings{
+		SchemaVersion: SchemaVersion,
+		LandingPage:   DefaultLandingPage,
+	}
+}
+
+// Upgrade returns s in the current layout. Orgs created before settings
+// existed have none (version 0) and get the defaults.
+func Upgrade(s *models.OrgSettings) (models.OrgSettings, error) {
+	if s == nil || s.SchemaVersion == 0 {
+		return Defaults(), nil
+	}
+	if s.SchemaVersion > SchemaVersion {
+		return models.OrgSettings{}, ErrNewerSchema
+	}
+	upgraded := *s
+	if upgraded.LandingPage == "" {
+		upgraded.LandingPage = DefaultLandingPage
+	}
+	upgraded.SchemaVersion = SchemaVersion
+	return upgraded, nil
+}
+
+// Normalize trims and lowercases s in place and reports the first invalid
+// field.
+func Normalize(s *models.OrgSettings) error {
+	b := &s.Branding
+	b.LogoURL = strings.TrimSpace(b.LogoURL)
+	if b.LogoURL != "" {
+		u, err := url.Parse(b.LogoURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return errors.New("logo_url must be an https URL")
+		}
+		if len(b.LogoURL) > 2048 {
+			return errors.New("logo_url is too long")
+		}
+	}
+	colors := []struct {
+		name  string
+		value *string
+	}{{"primary_color", &b.PrimaryColor}, {"accent_color", &b.AccentColor}}
+	for _, color := range colors {
+		*color.value = strings.ToLower(strings.TrimSpace(*color.value))
+		if *color.value != "" && !colorPattern.MatchString(*color.value) {
+			return fmt.Errorf("%s must be a hex color like #1a2b3c", color.name)
+		}
+	}
+
+	s.LandingPage = strings.TrimSpace(s.LandingPage)
+	if s.LandingPage == "" {
+		s.LandingPage = DefaultLandingPage
+	}
+	if !slices.Contains(LandingPages, s.LandingPage) {
+		return fmt.Errorf("landing_page must be one of %s", strings.Join(LandingPages, ", "))
+	}
+
+	s.ContactEmail = strings.TrimSpace(s.ContactEmail)
+	if s.ContactEmail != "" {
+		addr, err := mail.ParseAddress(s.ContactEmail)
+		if err != nil || addr.Address != s.ContactEmail {
+			return errors.New("contact_email must be a plain email address")
+		}
+	}
+	return nil
+}
+
+// publicBaseURL is where this server is reachable from outside, used to link
+// uploaded logos from emails. It comes from PUBLIC_BASE_URL.
+func publicBaseURL() string {
+	if base := os.Getenv("PUBLIC_BASE_URL"); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	return "http://localhost:8080"
+}
+
+// LogoURL is the URL the org's logo is shown from: the external URL, or the
+// public logo route for an uploaded logo. The key is part of the URL so a new
+// upload isn't hidden by caches.
+func LogoURL(orgID string, s models.OrgSettings) string {
+	if s.Branding.LogoKey != "" {
+		return publicBaseURL() + "/orgs/logo/" + orgID + "?v=" + url.QueryEscape(s.Branding.LogoKey)
+	}
+	return s.Branding.LogoURL
+}
+
+// Public returns the settings as shown to members, with LogoURL resolved.
+func Public(orgID string, s *models.OrgSettings) models.OrgSettings {
+	settings, err := Upgrade(s)
+	if err != nil {
+		// Still show what a newer server wrote; it just can't be edited here.
+		settings = *s
+	}
+	settings.Branding.LogoURL = LogoURL(orgID, settings)
+	return settings
+}
+
+// NotificationPayload is the branding passed to notification templates.
+func NotificationPayload(orgID string, s *models.OrgSettings) map[string]interface{} {
+	settings := Public(orgID, s)
+	return map[string]interface{}{
+		"logoUrl":      settings.Branding.LogoURL,
+		"primaryColor": settings.Branding.PrimaryColor,
+		"accentColor":  settings.Branding.AccentColor,
+		"contactEmail": settings.ContactEmail,
+		"landingPage":  settings.LandingPage,
+	}
+}
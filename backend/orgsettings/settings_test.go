@@ -0,0 +1,136 @@
+package orgsettings
+
+import (
+	"backend/models"
+	"errors"
+	"testing"
+)
+
+func TestUpgrade(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      *models.OrgSettings
+		want    models.OrgSettings
+		wantErr error
+	}{
+		{
+			name: "no settings",
+			in:   nil,
+			want: Defaults(),
+		},
+		{
+			name: "version 0",
+			in:   &models.OrgSettings{ContactEmail: "a@example.com"},
+			want: Defaults(),
+		},
+		{
+			name: "current version without landing page",
+			in:   &models.OrgSettings{SchemaVersion: 1, ContactEmail: "a@example.com"},
+			want: models.OrgSettings{SchemaVersion: 1, LandingPage: DefaultLandingPage, ContactEmail: "a@example.com"},
+		},
+		{
+			name: "current version kept",
+			in:   &models.OrgSettings{SchemaVersion: 1, LandingPage: "repos"},
+			want: models.OrgSettings{SchemaVersion: 1, LandingPage: "repos"},
+		},
+		{
+			name:    "newer version",
+			in:      &models.OrgSettings{SchemaVersion: SchemaVersion + 1},
+			wantErr: ErrNewerSchema,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Upgrade(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Upgrade() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("Upgrade() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      models.OrgSettings
+		want    models.OrgSettings
+		wantErr bool
+	}{
+		{
+			name: "empty gets the default landing page",
+			in:   models.OrgSettings{},
+			want: models.OrgSettings{LandingPage: DefaultLandingPage},
+		},
+		{
+			name: "trims and lowercases",
+			in: models.OrgSettings{
+				Branding: models.OrgBranding{
+					LogoURL:      " https://cdn.example.com/logo.png ",
+					PrimaryColor: " #AABBCC",
+					AccentColor:  "#FFF ",
+				},
+				LandingPage:  " members ",
+				ContactEmail: " team@example.com ",
+			},
+			want: models.OrgSettings{
+				Branding: models.OrgBranding{
+					LogoURL:      "https://cdn.example.com/logo.png",
+					PrimaryColor: "#aabbcc",
+					AccentColor:  "#fff",
+				},
+				LandingPage:  "members",
+				ContactEmail: "team@example.com",
+			},
+		},
+		{
+			name:    "http logo url",
+			in:      models.OrgSettings{Branding: models.OrgBranding{LogoURL: "http://example.com/logo.png"}},
+			wantErr: true,
+		},
+		{
+			name:    "logo url without host",
+			in:      models.OrgSettings{Branding: models.OrgBranding{LogoURL: "https:///logo.png"}},
+			wantErr: true,
+		},
+		{
+			name:    "named color",
+			in:      models.OrgSettings{Branding: models.OrgBranding{PrimaryColor: "red"}},
+			wantErr: true,
+		},
+		{
+			name:    "color without hash",
+			in:      models.OrgSettings{Branding: models.OrgBranding{AccentColor: "aabbcc"}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown landing page",
+			in:      models.OrgSettings{LandingPage: "billing"},
+			wantErr: true,
+		},
+		{
+			name:    "contact email with display name",
+			in:      models.OrgSettings{ContactEmail: "Team <team@example.com>"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid contact email",
+			in:      models.OrgSettings{ContactEmail: "not an email"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.in
+			err := Normalize(&got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("Normalize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// Package plans holds the per-org plans and the usage checks that enforce
+// their limits.
+package plans
+
+import (
+	"backend/db"
+	"context"
+	"fmt"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Unlimited disables a limit.
+const Unlimited = -1
+
+const Default = "free"
+
+// Limit names, as reported in errors and usage.
+const (
+	LimitMembers        = "members"
+	LimitPendingInvites = "pending_invites"
+	LimitRepos          = "repos"
+	LimitCustomRoles    = "custom_roles"
+)
+
+type Limits struct {
+	Members        int64 `json:"members"`
+	PendingInvites int64 `json:"pending_invites"`
+	Repos          int64 `json:"repos"`
+	CustomRoles    int64 `json:"custom_roles"`
+}
+
+func (l Limits) get(limit string) int64 {
+	switch limit {
+	case LimitMembers:
+		return l.Members
+	case LimitPendingInvites:
+		return l.PendingInvites
+	case LimitRepos:
+		return l.Repos
+	case LimitCustomRoles:
+		return l.CustomRoles
+	}
+	return Unlimited
+}
+
+type Plan struct {
+	Name   string `json:"name"`
+	Limits Limits `json:"limits"`
+}
+
+var Plans = map[string]Plan{
+	"free": {Name: "free", Limits: Limits{Members: 10, PendingInvites: 10, Repos: 5, CustomRoles: 0}},
+	"team": {Name: "team", Limits: Limits{Members: 100, PendingInvites: 100, Repos: 100, CustomRoles: 10}},
+	"enterprise": {Name: "enterprise", Limits: Limits{
+		Members: Unlimited, PendingInvites: Unlimited, Repos: Unlimited, CustomRoles: Unlimited,
+	}},
+}
+
+// Get returns the named plan, falling back to the default plan for orgs
+// created before plans existed.
+func Get(name string) Plan {
+	if plan, ok := Plans[name]; ok {
+		return plan
+	}
+	return Plans[Default]
+}
+
+// LimitError is returned when an action would take an org past a limit of
+// its plan.
+type LimitError struct {
+	Plan    string
+	Limit   string
+	Max     int64
+	Current int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("Organization has reached its %s limit (%d) on the %s plan",
+		e.Limit, e.Max, e.Plan)
+}
+
+// builtinRoles are not counted as custom roles.
+var builtinRoles = []string{"admin", "writer", "reader", "invite"}
+
+func OrgPlan(ctx context.Context, orgID string) (Plan, error) {
+	objID, err := primitive.ObjectIDFromHex(orgID)
+	if err != nil {
+		return Plan{}, err
+	}
+	var org struct {
+		Plan string `bson:"plan"`
+	}
+	if err := db.GetOrgCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&org); err != nil {
+		return Plan{}, err
+	}
+	return Get(org.Plan), nil
+}
+
+// IsBuiltinRole reports whether role is one every org has.
+func IsBuiltinRole(role string) bool {
+	return slices.Contains(builtinRoles, role)
+}
+
+// CustomRoles lists the org's custom roles: any p rule subjects in the org
+// domain besides the built-in roles, teams and project roles.
+func CustomRoles(ctx context.Context, orgID string) ([]string, error) {
+	raw, err := db.GetCasbinRuleCollection().Distinct(ctx, "v0", bson.M{
+		"ptype": "p",
+		"v1":    orgID,
+		"v0":    bson.M{"$nin": builtinRoles, "$not": primitive.Regex{Pattern: "^(team|project):"}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]string, 0, len(raw))
+	for _, r := range raw {
+		if role, ok := r.(string); ok {
+			roles = append(roles, role)
+		}
+	}
+	return roles, nil
+}
+
+// Count returns the org's current usage of limit.
+func Count(ctx context.Context, orgID, limit string) (int64, error) {
+	switch limit {
+	case LimitMembers:
+		return db.GetMembershipCollection().CountDocuments(ctx, bson.M{"org_id": orgID})
+	case LimitPendingInvites:
+		return db.GetInviteCollection().CountDocuments(ctx, bson.M{"org_id": orgID, "status": "pending"})
+	case LimitRepos:
+		return db.GetRepoCollection().CountDocuments(ctx, bson.M{"org_id": orgID})
+	case LimitCustomRoles:
+		roles, err := CustomRoles(ctx, orgID)
+		return int64(len(roles)), err
+	}
+	return 0, fmt.Errorf("unknown limit %q", limit)
+}
+
+// Check returns a *LimitError when adding one more of limit would exceed the
+// org's plan. It counts before the caller inserts, so concurrent callers can
+// both pass; callers that can undo their insert follow up with Recheck.
+func Check(ctx context.Context, orgID, limit string) error {
+	return CheckN(ctx, orgID, limit, 1)
+}
+
+// Recheck returns a *LimitError when the org is already past limit, for
+// callers to undo an insert that raced another one past Check. When both
+// racers see the overrun, both back out.
+func Recheck(ctx context.Context, orgID, limit string) error {
+	return CheckN(ctx, orgID, limit, 0)
+}
+
+// CheckN is Check for adding n at once.
+func CheckN(ctx context.Context, orgID, limit string, n int64) error {
+	plan, err := OrgPlan(ctx, orgID)
+	if err != nil {
+		return err
+	}
+	max := plan.Limits.get(limit)
+	if max == Unlimited {
+		return nil
+	}
+	current, err := Count(ctx, orgID, limit)
+	if err != nil {
+		return err
+	}
+	if current+n > max {
+		return &LimitError{Plan: plan.Name, Limit: limit, Max: max, Current: current}
+	}
+	return nil
+}
+
+type Usage struct {
+	Plan   string           `json:"plan"`
+	Limits Limits           `json:"limits"`
+	Usage  map[string]int64 `json:"usage"`
+}
+
+// OrgUsage reports the org's usage of every limit next to its plan.
+func OrgUsage(ctx context.Context, orgID string) (Usage, error) {
+	plan, err := OrgPlan(ctx, orgID)
+	if err != nil {
+		return Usage{}, err
+	}
+	usage := Usage{Plan: plan.Name, Limits: plan.Limits, Usage: map[string]int64{}}
+	for _, limit := range []string{LimitMembers, LimitPendingInvites, LimitRepos, LimitCustomRoles} {
+		n, err := Count(ctx, orgID, limit)
+		if err != nil {
+			return Usage{}, err
+		}
+		usage.Usage[limit] = n
+	}
+	return usage, nil
+}
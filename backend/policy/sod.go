@@ -0,0 +1,172 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/casbin/casbin/v2"
+)
+
+// Constraint is a set of mutually exclusive roles: a user may hold at most one
+// of them in any single domain. An empty Domains list applies the constraint
+// to every domain, including orgs.
+type Constraint struct {
+	Name    string   `yaml:"name"`
+	Roles   []string `yaml:"roles"`
+	Domains []string `yaml:"domains"`
+}
+
+func (c Constraint) appliesTo(dom string) bool {
+	return len(c.Domains) == 0 || slices.Contains(c.Domains, dom)
+}
+
+// Violation is returned when a role grant would break separation of duties.
+type Violation struct {
+	Constraint string
+	UserID     string
+	Domain     string
+	Role       string
+	Conflicts  []string
+	Reason     string
+}
+
+func (v *Violation) Error() string {
+	if v.Reason != "" {
+		return v.Reason
+	}
+	return fmt.Sprintf("separation of duties violation (%s): role %q cannot be held together with %s in %q",
+		v.Constraint, v.Role, strings.Join(v.Conflicts, ", "), v.Domain)
+}
+
+var (
+	constraintsMu sync.RWMutex
+	constraints   []Constraint
+)
+
+// LoadConstraints reads the separation_of_duties sections of the policy
+// directory and makes them the active constraint set.
+func LoadConstraints(dir string) error {
+	spec, err := Load(dir)
+	if err != nil {
+		return err
+	}
+	SetConstraints(spec.Constraints)
+	return nil
+}
+
+func SetConstraints(c []Constraint) {
+	constraintsMu.Lock()
+	defer constraintsMu.Unlock()
+	constraints = c
+}
+
+func activeConstraints() []Constraint {
+	constraintsMu.RLock()
+	defer constraintsMu.RUnlock()
+	return constraints
+}
+
+// impliedRoles returns role together with every role it inherits in dom.
+func impliedRoles(e *casbin.Enforcer, role, dom string) []string {
+	roles := []string{role}
+	inherited, err := e.GetImplicitRolesForUser(role, dom)
+	if err == nil {
+		roles = append(roles, inherited...)
+	}
+	return roles
+}
+
+// isLink reports whether a grouping target is a link such as "team:<id>" or
+// "project:<id>:<role>" rather than a plain role. Links survive a role
+// replacement.
+func isLink(role string) bool {
+	return strings.Contains(role, ":")
+}
+
+// CheckSeparation reports whether granting role to user in dom would leave
+// them holding two roles of the same exclusive set. When replace is true the
+// user's direct roles in dom are about to be dropped and are ignored; roles
+// inherited through team and project links still count.
+func CheckSeparation(e *casbin.Enforcer, user, role, dom string, replace bool) error {
+	held := map[string]bool{}
+	if !replace {
+		current, _ := e.GetImplicitRolesForUser(user, dom)
+		for _, r := range current {
+			held[r] = true
+		}
+	} else {
+		for _, direct := range e.GetRolesForUserInDomain(user, dom) {
+			if !isLink(direct) {
+				continue
+			}
+			for _, r := range impliedRoles(e, direct, dom) {
+				held[r] = true
+			}
+		}
+	}
+	for _, r := range impliedRoles(e, role, dom) {
+		held[r] = true
+	}
+
+	for _, constraint := range activeConstraints() {
+		if !constraint.appliesTo(dom) {
+			continue
+		}
+		var matched []string
+		for _, r := range constraint.Roles {
+			if held[r] {
+				matched = append(matched, r)
+			}
+		}
+		if len(matched) < 2 {
+			continue
+		}
+		conflicts := slices.DeleteFunc(matched, func(r string) bool { return r == role })
+		return &Violation{
+			Constraint: constraint.Name,
+			UserID:     user,
+			Domain:     dom,
+			Role:       role,
+			Conflicts:  conflicts,
+		}
+	}
+	return nil
+}
+
+// CheckSelfElevation stops a user from granting themselves a role they do
+// not already hold. Lowering one's own role is allowed.
+func CheckSelfElevation(e *casbin.Enforcer, actor, user, role, dom string) error {
+	if actor == "" || actor != user {
+		return nil
+	}
+	current, _ := e.GetImplicitRolesForUser(user, dom)
+	if slices.Contains(current, role) {
+		return nil
+	}
+	return &Violation{
+		UserID: user,
+		Domain: dom,
+		Role:   role,
+		Reason: fmt.Sprintf("separation of duties violation: you cannot approve your own elevation to %q", role),
+	}
+}
+
+// AddRoleForUserInDomain is enforcer.AddRoleForUserInDomain guarded by the
+// separation-of-duties constraints.
+func AddRoleForUserInDomain(e *casbin.Enforcer, user, role, dom string) (bool, error) {
+	if err := CheckSeparation(e, user, role, dom, false); err != nil {
+		return false, err
+	}
+	return e.AddRoleForUserInDomain(user, role, dom)
+}
+
+// AddGroupingPolicy is enforcer.AddGroupingPolicy for a user-to-role link,
+// guarded by the separation-of-duties constraints.
+func AddGroupingPolicy(e *casbin.Enforcer, user, role, dom string) (bool, error) {
+	if err := CheckSeparation(e, user, role, dom, false); err != nil {
+		return false, err
+	}
+	return e.AddGroupingPolicy(user, role, dom)
+}
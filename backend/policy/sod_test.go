@@ -0,0 +1,99 @@
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+)
+
+func TestCheckSeparation(t *testing.T) {
+	e, err := casbin.NewEnforcer("../model.config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const org = "org1"
+	for _, g := range [][]string{
+		{"alice", "requester", org},
+		{"bob", "team:t1", org},
+		{"team:t1", "requester", org},
+		{"lead", "approver", org},
+		{"carol", "requester", "org2"},
+	} {
+		if _, err := e.AddGroupingPolicy(g[0], g[1], g[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	SetConstraints([]Constraint{
+		{Name: "request-approve", Roles: []string{"requester", "approver"}},
+		{Name: "org2-only", Roles: []string{"requester", "auditor"}, Domains: []string{"org2"}},
+	})
+	defer SetConstraints(nil)
+
+	tests := []struct {
+		name          string
+		user, role    string
+		dom           string
+		replace       bool
+		wantViolation string
+		wantConflicts []string
+	}{
+		{name: "direct conflict", user: "alice", role: "approver", dom: org, wantViolation: "request-approve", wantConflicts: []string{"requester"}},
+		{name: "conflict through an inherited role", user: "alice", role: "lead", dom: org, wantViolation: "request-approve", wantConflicts: []string{"requester", "approver"}},
+		{name: "replacing the direct role", user: "alice", role: "approver", dom: org, replace: true},
+		{name: "team link survives replacement", user: "bob", role: "approver", dom: org, replace: true, wantViolation: "request-approve", wantConflicts: []string{"requester"}},
+		{name: "same role again", user: "alice", role: "requester", dom: org},
+		{name: "no roles held", user: "dave", role: "approver", dom: org},
+		{name: "constraint scoped to another domain", user: "alice", role: "auditor", dom: org},
+		{name: "domain-scoped constraint", user: "carol", role: "auditor", dom: "org2", wantViolation: "org2-only", wantConflicts: []string{"requester"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSeparation(e, tt.user, tt.role, tt.dom, tt.replace)
+			if tt.wantViolation == "" {
+				if err != nil {
+					t.Fatalf("CheckSeparation() error = %v, want nil", err)
+				}
+				return
+			}
+			var v *Violation
+			if !errors.As(err, &v) {
+				t.Fatalf("CheckSeparation() error = %v, want a violation", err)
+			}
+			if v.Constraint != tt.wantViolation || !reflect.DeepEqual(v.Conflicts, tt.wantConflicts) {
+				t.Errorf("violation = %s %v, want %s %v", v.Constraint, v.Conflicts, tt.wantViolation, tt.wantConflicts)
+			}
+		})
+	}
+}
+
+func TestCheckSelfElevation(t *testing.T) {
+	e, err := casbin.NewEnforcer("../model.config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.AddGroupingPolicy("alice", "admin", "org1")
+	e.AddGroupingPolicy("admin", "writer", "org1")
+
+	tests := []struct {
+		name        string
+		actor, user string
+		role        string
+		wantErr     bool
+	}{
+		{"raising someone else", "alice", "bob", "admin", false},
+		{"keeping own role", "alice", "alice", "admin", false},
+		{"lowering own role", "alice", "alice", "writer", false},
+		{"raising self", "bob", "bob", "admin", true},
+		{"no actor", "", "bob", "admin", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckSelfElevation(e, tt.actor, tt.user, tt.role, "org1")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckSelfElevation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
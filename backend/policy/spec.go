@@ -0,0 +1,225 @@
+package policy
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/casbin/casbin/v2/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v3"
+)
+
+// File is the on-disk shape of a single policy file. A directory may hold any
+// number of them; they are merged by Load.
+type File struct {
+	Domain             string          `yaml:"domain"`
+	Roles              map[string]Role `yaml:"roles"`
+	Policies           []Rule          `yaml:"policies"`
+	SeparationOfDuties []Constraint    `yaml:"separation_of_duties"`
+}
+
+type Role struct {
+	Inherits []string `yaml:"inherits"`
+}
+
+type Rule struct {
+	Role   string `yaml:"role"`
+	Path   string `yaml:"path"`
+	Method string `yaml:"method"`
+}
+
+// Spec is the merged, flattened view of a policy directory.
+type Spec struct {
+	Revision    string
+	Domains     []string
+	Roles       map[string]map[string]Role
+	P           [][]string
+	G           [][]string
+	Constraints []Constraint
+}
+
+var validMethods = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true,
+}
+
+func Load(dir string) (*Spec, error) {
+	spec := &Spec{Roles: map[string]map[string]Role{}}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := filepath.Ext(path)
+		if ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var file File
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if file.Domain == "" {
+			file.Domain = "main"
+		}
+		if spec.Roles[file.Domain] == nil {
+			spec.Roles[file.Domain] = map[string]Role{}
+			spec.Domains = append(spec.Domains, file.Domain)
+		}
+		for name, role := range file.Roles {
+			if _, exists := spec.Roles[file.Domain][name]; exists {
+				return fmt.Errorf("%s: role %q already defined in domain %q", path, name, file.Domain)
+			}
+			spec.Roles[file.Domain][name] = role
+		}
+		for _, rule := range file.Policies {
+			spec.P = append(spec.P, []string{rule.Role, file.Domain, rule.Path, strings.ToUpper(rule.Method)})
+		}
+		spec.Constraints = append(spec.Constraints, file.SeparationOfDuties...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(spec.Domains)
+	for _, dom := range spec.Domains {
+		for name, role := range spec.Roles[dom] {
+			for _, parent := range role.Inherits {
+				spec.G = append(spec.G, []string{name, parent, dom})
+			}
+		}
+	}
+	spec.Revision = gitRevision(dir)
+	return spec, nil
+}
+
+// Validate checks the spec against the Casbin model it will be loaded into
+// and against itself: every referenced role must be declared and the role
+// hierarchy must be acyclic.
+func (s *Spec) Validate(modelPath string) error {
+	m, err := model.NewModelFromFile(modelPath)
+	if err != nil {
+		return fmt.Errorf("failed to load model: %w", err)
+	}
+	p, err := m.GetAssertion("p", "p")
+	if err != nil {
+		return fmt.Errorf("model has no policy definition: %w", err)
+	}
+	g, err := m.GetAssertion("g", "g")
+	if err != nil {
+		return fmt.Errorf("model has no role definition: %w", err)
+	}
+
+	for _, dom := range s.Domains {
+		if primitive.IsValidObjectID(dom) {
+			return fmt.Errorf("domain %q is an organization; org rules are managed through the API", dom)
+		}
+	}
+	for _, rule := range s.P {
+		if len(rule) != len(p.Tokens) {
+			return fmt.Errorf("policy %v has %d fields, model expects %d", rule, len(rule), len(p.Tokens))
+		}
+		if _, ok := s.Roles[rule[1]][rule[0]]; !ok {
+			return fmt.Errorf("policy %v references undeclared role %q", rule, rule[0])
+		}
+		if !strings.HasPrefix(rule[2], "/") {
+			return fmt.Errorf("policy %v: path must start with /", rule)
+		}
+		if !validMethods[rule[3]] {
+			return fmt.Errorf("policy %v: unsupported method %q", rule, rule[3])
+		}
+	}
+	for _, rule := range s.G {
+		if len(rule) != len(g.Tokens) {
+			return fmt.Errorf("role rule %v has %d fields, model expects %d", rule, len(rule), len(g.Tokens))
+		}
+		if _, ok := s.Roles[rule[2]][rule[1]]; !ok {
+			return fmt.Errorf("role %q inherits undeclared role %q", rule[0], rule[1])
+		}
+	}
+	for _, dom := range s.Domains {
+		if err := checkCycles(dom, s.Roles[dom]); err != nil {
+			return err
+		}
+	}
+	for _, constraint := range s.Constraints {
+		if len(constraint.Roles) < 2 {
+			return fmt.Errorf("separation of duties constraint %q needs at least two roles", constraint.Name)
+		}
+	}
+	return nil
+}
+
+func checkCycles(dom string, roles map[string]Role) error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("role hierarchy in domain %q has a cycle through %q", dom, name)
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		for _, parent := range roles[name].Inherits {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		return nil
+	}
+	for name := range roles {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isGitTree(dir string) bool {
+	return exec.Command("git", "-C", dir, "rev-parse", "--is-inside-work-tree").Run() == nil
+}
+
+func gitRevision(dir string) string {
+	if !isGitTree(dir) {
+		return ""
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Pull fast-forwards the git working tree at dir. Plain directories are left
+// alone.
+func Pull(dir string) error {
+	if !isGitTree(dir) {
+		return nil
+	}
+	out, err := exec.Command("git", "-C", dir, "pull", "--ff-only").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git pull failed: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package policy
+
+import (
+	"backend/db"
+	"backend/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// syncState remembers which rules the last sync applied, so that rules
+// dropped from the repository can be removed without touching the ones
+// created at runtime through the API.
+type syncState struct {
+	ID        string     `bson:"_id"`
+	Revision  string     `bson:"revision"`
+	P         [][]string `bson:"p"`
+	G         [][]string `bson:"g"`
+	AppliedAt time.Time  `bson:"applied_at"`
+
+	// Constraints are shared with processes that didn't run the sync; nil
+	// for states saved before they were recorded.
+	Constraints *[]Constraint `bson:"constraints,omitempty"`
+}
+
+const syncStateID = "base"
+
+type Plan struct {
+	AddP    [][]string
+	RemoveP [][]string
+	AddG    [][]string
+	RemoveG [][]string
+}
+
+func (p Plan) Empty() bool {
+	return len(p.AddP)+len(p.RemoveP)+len(p.AddG)+len(p.RemoveG) == 0
+}
+
+func ruleKey(rule []string) string {
+	return strings.Join(rule, "\x00")
+}
+
+func ruleSet(rules [][]string) map[string]bool {
+	set := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		set[ruleKey(rule)] = true
+	}
+	return set
+}
+
+func domainsOf(rules [][]string, idx int) map[string]bool {
+	domains := map[string]bool{}
+	for _, rule := range rules {
+		if len(rule) > idx {
+			domains[rule[idx]] = true
+		}
+	}
+	return domains
+}
+
+// Diff compares the spec with the live enforcer. Only rules in the managed
+// scope are considered: policies in the spec's domains, and role-to-role
+// links between declared roles. User assignments and org domains are never
+// part of the plan.
+func Diff(e *casbin.Enforcer, spec *Spec, prev *syncState) (Plan, error) {
+	var plan Plan
+
+	livePolicies, err := e.GetPolicy()
+	if err != nil {
+		return plan, err
+	}
+	liveGrouping, err := e.GetGroupingPolicy()
+	if err != nil {
+		return plan, err
+	}
+
+	pDomains := domainsOf(spec.P, 1)
+	for _, dom := range spec.Domains {
+		pDomains[dom] = true
+	}
+	prevG := map[string]bool{}
+	if prev != nil {
+		for dom := range domainsOf(prev.P, 1) {
+			pDomains[dom] = true
+		}
+		prevG = ruleSet(prev.G)
+	}
+
+	desiredP := ruleSet(spec.P)
+	liveP := ruleSet(livePolicies)
+	for _, rule := range livePolicies {
+		if len(rule) > 1 && pDomains[rule[1]] && !desiredP[ruleKey(rule)] {
+			plan.RemoveP = append(plan.RemoveP, rule)
+		}
+	}
+	for _, rule := range spec.P {
+		if !liveP[ruleKey(rule)] {
+			plan.AddP = append(plan.AddP, rule)
+		}
+	}
+
+	desiredG := ruleSet(spec.G)
+	liveG := ruleSet(liveGrouping)
+	for _, rule := range liveGrouping {
+		if len(rule) < 3 || desiredG[ruleKey(rule)] {
+			continue
+		}
+		roles := spec.Roles[rule[2]]
+		_, subIsRole := roles[rule[0]]
+		_, objIsRole := roles[rule[1]]
+		if prevG[ruleKey(rule)] || (subIsRole && objIsRole) {
+			plan.RemoveG = append(plan.RemoveG, rule)
+		}
+	}
+	for _, rule := range spec.G {
+		if !liveG[ruleKey(rule)] {
+			plan.AddG = append(plan.AddG, rule)
+		}
+	}
+	return plan, nil
+}
+
+func Apply(e *casbin.Enforcer, plan Plan) error {
+	if len(plan.RemoveP) > 0 {
+		if _, err := e.RemovePolicies(plan.RemoveP); err != nil {
+			return fmt.Errorf("failed to remove policies: %w", err)
+		}
+	}
+	if len(plan.RemoveG) > 0 {
+		if _, err := e.RemoveGroupingPolicies(plan.RemoveG); err != nil {
+			return fmt.Errorf("failed to remove role rules: %w", err)
+		}
+	}
+	if len(plan.AddP) > 0 {
+		if _, err := e.AddPolicies(plan.AddP); err != nil {
+			return fmt.Errorf("failed to add policies: %w", err)
+		}
+	}
+	if len(plan.AddG) > 0 {
+		if _, err := e.AddGroupingPolicies(plan.AddG); err != nil {
+			return fmt.Errorf("failed to add role rules: %w", err)
+		}
+	}
+	return nil
+}
+
+func loadState(ctx context.Context) (*syncState, error) {
+	var state syncState
+	err := db.GetPolicySyncCollection().FindOne(ctx, bson.M{"_id": syncStateID}).Decode(&state)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func saveState(ctx context.Context, spec *Spec) error {
+	state := syncState{
+		ID:        syncStateID,
+		Revision:  spec.Revision,
+		P:         spec.P,
+		G:         spec.G,
+		AppliedAt: time.Now(),
+	}
+	if spec.Constraints != nil {
+		state.Constraints = &spec.Constraints
+	} else {
+		state.Constraints = &[]Constraint{}
+	}
+	_, err := db.GetPolicySyncCollection().ReplaceOne(ctx, bson.M{"_id": syncStateID}, state, options.Replace().SetUpsert(true))
+	return err
+}
+
+// RefreshConstraints makes the last sync's constraints the active set. Syncs
+// run in the Temporal worker, so the API server calls this periodically.
+func RefreshConstraints(ctx context.Context) error {
+	state, err := loadState(ctx)
+	if err != nil || state == nil || state.Constraints == nil {
+		return err
+	}
+	SetConstraints(*state.Constraints)
+	return nil
+}
+
+// Sync loads the policy directory, validates it against the model and brings
+// the live Casbin state in line with it.
+func Sync(ctx context.Context, e *casbin.Enforcer, input models.PolicySyncInput) (models.PolicySyncResult, error) {
+	result := models.PolicySyncResult{DryRun: input.DryRun}
+
+	if input.Pull {
+		if err := Pull(input.Dir); err != nil {
+			return result, err
+		}
+	}
+	spec, err := Load(input.Dir)
+	if err != nil {
+		return result, fmt.Errorf("failed to load policies: %w", err)
+	}
+	result.Revision = spec.Revision
+	if err := spec.Validate(input.ModelPath); err != nil {
+		return result, fmt.Errorf("invalid policies: %w", err)
+	}
+
+	if err := e.LoadPolicy(); err != nil {
+		return result, err
+	}
+	prev, err := loadState(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to load sync state: %w", err)
+	}
+	plan, err := Diff(e, spec, prev)
+	if err != nil {
+		return result, err
+	}
+	result.Added = append(plan.AddP, plan.AddG...)
+	result.Removed = append(plan.RemoveP, plan.RemoveG...)
+	if input.DryRun {
+		return result, nil
+	}
+
+	if !plan.Empty() {
+		if err := Apply(e, plan); err != nil {
+			return result, err
+		}
+	}
+	SetConstraints(spec.Constraints)
+	if err := saveState(ctx, spec); err != nil {
+		return result, fmt.Errorf("failed to save sync state: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,147 @@
+// Package secrets stores org secrets with envelope encryption: every org has
+// its own data key, and data keys are stored wrapped with a master key that
+// never touches the database.
+package secrets
+
+import (
+	"backend/db"
+	"backend/models"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const keySize = 32
+
+var ErrNoMasterKey = errors.New("secrets master key is not configured")
+
+// ErrMasterKeyMismatch means a data key was wrapped with a different master
+// key than the one configured now.
+var ErrMasterKeyMismatch = errors.New("org data key was wrapped with another master key")
+
+var (
+	masterOnce  sync.Once
+	masterKey   []byte
+	masterKeyID string
+	masterErr   error
+)
+
+// loadMasterKey reads the base64 master key from SECRETS_MASTER_KEY, or from
+// the file named by SECRETS_MASTER_KEY_FILE.
+func loadMasterKey() ([]byte, string, error) {
+	masterOnce.Do(func() {
+		encoded := os.Getenv("SECRETS_MASTER_KEY")
+		if path := os.Getenv("SECRETS_MASTER_KEY_FILE"); encoded == "" && path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				masterErr = fmt.Errorf("failed to read master key file: %w", err)
+				return
+			}
+			encoded = string(data)
+		}
+		encoded = strings.TrimSpace(encoded)
+		if encoded == "" {
+			masterErr = ErrNoMasterKey
+			return
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != keySize {
+			masterErr = fmt.Errorf("master key must be %d base64-encoded bytes", keySize)
+			return
+		}
+		sum := sha256.Sum256(key)
+		masterKey, masterKeyID = key, hex.EncodeToString(sum[:8])
+	})
+	return masterKey, masterKeyID, masterErr
+}
+
+// seal encrypts plaintext with AES-GCM, prefixing the nonce. aad binds the
+// ciphertext to where it is stored.
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, ciphertext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// dataKey returns the org's unwrapped data key, creating it on first use.
+func dataKey(ctx context.Context, orgID string) ([]byte, error) {
+	master, masterID, err := loadMasterKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var stored models.OrgDataKey
+	err = db.GetOrgKeyCollection().FindOne(ctx, bson.M{"org_id": orgID}).Decode(&stored)
+	if err == mongo.ErrNoDocuments {
+		stored, err = createDataKey(ctx, orgID, master, masterID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stored.MasterKeyID != masterID {
+		return nil, ErrMasterKeyMismatch
+	}
+	return open(master, stored.WrappedKey, []byte(orgID))
+}
+
+func createDataKey(ctx context.Context, orgID string, master []byte, masterID string) (models.OrgDataKey, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return models.OrgDataKey{}, err
+	}
+	wrapped, err := seal(master, key, []byte(orgID))
+	if err != nil {
+		return models.OrgDataKey{}, err
+	}
+	stored := models.OrgDataKey{
+		OrgID:       orgID,
+		WrappedKey:  wrapped,
+		MasterKeyID: masterID,
+		CreatedAt:   time.Now(),
+	}
+	_, err = db.GetOrgKeyCollection().InsertOne(ctx, stored)
+	if mongo.IsDuplicateKeyError(err) {
+		// Another request created it first; use theirs.
+		err = db.GetOrgKeyCollection().FindOne(ctx, bson.M{"org_id": orgID}).Decode(&stored)
+	}
+	return stored, err
+}
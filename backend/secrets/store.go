@@ -0,0 +1,178 @@
+package secrets
+
+import (
+	"backend/db"
+	"backend/models"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaxValueSize caps a single secret value.
+const MaxValueSize = 64 << 10
+
+var ErrNotFound = errors.New("secret not found")
+
+var namePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]{0,127}$`)
+
+// DefaultReadRoles applies when a secret is stored without read roles.
+var DefaultReadRoles = []string{"admin"}
+
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// aad ties a ciphertext to its org, name and version so stored values can't
+// be swapped between documents.
+func aad(orgID, name string, version int) []byte {
+	return []byte(fmt.Sprintf("%s/%s/%d", orgID, name, version))
+}
+
+// Put stores value as a new version of the secret and returns it.
+func Put(ctx context.Context, orgID, name, value string, readRoles []string, createdBy string) (models.Secret, error) {
+	if len(readRoles) == 0 {
+		readRoles = DefaultReadRoles
+	}
+	key, err := dataKey(ctx, orgID)
+	if err != nil {
+		return models.Secret{}, err
+	}
+
+	// Retry if a concurrent Put took the version we picked.
+	for attempt := 0; attempt < 3; attempt++ {
+		version := 1
+		if latest, err := Latest(ctx, orgID, name); err == nil {
+			version = latest.Version + 1
+		} else if !errors.Is(err, ErrNotFound) {
+			return models.Secret{}, err
+		}
+
+		ciphertext, err := seal(key, []byte(value), aad(orgID, name, version))
+		if err != nil {
+			return models.Secret{}, err
+		}
+		secret := models.Secret{
+			OrgID:      orgID,
+			Name:       name,
+			Version:    version,
+			Ciphertext: ciphertext,
+			ReadRoles:  readRoles,
+			CreatedBy:  createdBy,
+			CreatedAt:  time.Now(),
+		}
+		_, err = db.GetSecretCollection().InsertOne(ctx, secret)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		return secret, err
+	}
+	return models.Secret{}, errors.New("failed to allocate a secret version")
+}
+
+// Latest returns the newest version's metadata.
+func Latest(ctx context.Context, orgID, name string) (models.Secret, error) {
+	return find(ctx, orgID, name, 0)
+}
+
+// Get returns a version of the secret and its decrypted value. Version 0 is
+// the latest.
+func Get(ctx context.Context, orgID, name string, version int) (models.Secret, string, error) {
+	secret, err := find(ctx, orgID, name, version)
+	if err != nil {
+		return secret, "", err
+	}
+	key, err := dataKey(ctx, orgID)
+	if err != nil {
+		return secret, "", err
+	}
+	plaintext, err := open(key, secret.Ciphertext, aad(orgID, name, secret.Version))
+	if err != nil {
+		return secret, "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return secret, string(plaintext), nil
+}
+
+func find(ctx context.Context, orgID, name string, version int) (models.Secret, error) {
+	var secret models.Secret
+	filter := bson.M{"org_id": orgID, "name": name}
+	opts := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+	if version > 0 {
+		filter["version"] = version
+	}
+	err := db.GetSecretCollection().FindOne(ctx, filter, opts).Decode(&secret)
+	if err == mongo.ErrNoDocuments {
+		return secret, ErrNotFound
+	}
+	return secret, err
+}
+
+// List returns the latest version of every secret in the org, without values.
+func List(ctx context.Context, orgID string) ([]models.Secret, error) {
+	cursor, err := db.GetSecretCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"org_id": orgID}}},
+		{{Key: "$sort", Value: bson.D{{Key: "name", Value: 1}, {Key: "version", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$name", "doc": bson.M{"$first": "$$ROOT"}}}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$doc"}}},
+		{{Key: "$project", Value: bson.M{"ciphertext": 0}}},
+		{{Key: "$sort", Value: bson.M{"name": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	secrets := []models.Secret{}
+	if err := cursor.All(ctx, &secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// Versions returns every version of a secret, newest first, without values.
+func Versions(ctx context.Context, orgID, name string) ([]models.Secret, error) {
+	cursor, err := db.GetSecretCollection().Find(ctx, bson.M{"org_id": orgID, "name": name},
+		options.Find().SetSort(bson.D{{Key: "version", Value: -1}}).SetProjection(bson.M{"ciphertext": 0}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var versions []models.Secret
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+	return versions, nil
+}
+
+// Delete removes every version of a secret.
+func Delete(ctx context.Context, orgID, name string) error {
+	res, err := db.GetSecretCollection().DeleteMany(ctx, bson.M{"org_id": orgID, "name": name})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CanRead reports whether any of roles may read the secret. Access follows
+// the latest version's read roles so tightening them covers old versions.
+func CanRead(latest models.Secret, roles []string) bool {
+	for _, role := range latest.ReadRoles {
+		if slices.Contains(roles, role) {
+			return true
+		}
+	}
+	return false
+}
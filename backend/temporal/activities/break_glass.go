@@ -0,0 +1,38 @@
+package activities
+
+import (
+	"backend/models"
+	"backend/policy"
+	"backend/utils"
+	"context"
+	"errors"
+	"slices"
+)
+
+func (a *CasbinActivities) GrantBreakGlassActivity(ctx context.Context, input models.BreakGlassInput) (bool, error) {
+	_ = a.Enforcer.LoadPolicy()
+	if slices.Contains(a.Enforcer.GetRolesForUserInDomain(input.UserId, "main"), "admin") {
+		return false, errors.New("User already has admin access")
+	}
+	added, err := policy.AddGroupingPolicy(a.Enforcer, input.UserId, "admin", "main")
+	if err != nil {
+		return false, err
+	}
+	return added, nil
+}
+
+func (a *CasbinActivities) RevokeBreakGlassActivity(ctx context.Context, input models.BreakGlassInput) (bool, error) {
+	_ = a.Enforcer.LoadPolicy()
+	removed, err := a.Enforcer.RemoveGroupingPolicy(input.UserId, "admin", "main")
+	if err != nil {
+		return false, err
+	}
+	return removed, nil
+}
+
+func BreakGlassAlertActivity(ctx context.Context, event string, input models.BreakGlassInput) (bool, error) {
+	if err := utils.BreakGlassAlert(ctx, event, input); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -1,18 +1,26 @@
 package activities
 
 import (
+	"backend/db"
 	"backend/models"
+	"backend/orgsettings"
+	"backend/plans"
+	"backend/policy"
+	"backend/utils"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/casbin/casbin/v2"
 	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func init() {
@@ -39,6 +47,7 @@ func SendInviteNotificationActivity(ctx context.Context, input models.CreateInvi
 			"orgName":     input.OrgName,
 			"description": input.Description,
 			"accepted":    false,
+			"branding":    inviteBranding(ctx, input.OrgID),
 		},
 	}
 
@@ -56,25 +65,24 @@ func SendInviteNotificationActivity(ctx context.Context, input models.CreateInvi
 	return true, nil
 }
 
-func FetchIdentitiesActivity(ctx context.Context) ([]map[string]interface{}, error) {
-	resp, err := http.Get("http://localhost:4434/admin/identities")
-	if err != nil {
-		return nil, errors.New("Failed to fetch identities")
+// inviteBranding loads the org's branding for the invite email. Without it
+// the email falls back to the default look, so errors are only logged.
+func inviteBranding(ctx context.Context, orgID string) map[string]interface{} {
+	var org models.Organization
+	objID, err := primitive.ObjectIDFromHex(orgID)
+	if err == nil {
+		err = db.GetOrgCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&org)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("Non-OK HTTP status")
-	}
-
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, errors.New("Failed to read body")
+		fmt.Printf("Warning: Failed to load branding for org %s: %v\n", orgID, err)
 	}
+	return orgsettings.NotificationPayload(orgID, org.Settings)
+}
 
-	var identities []map[string]interface{}
-	if err := json.Unmarshal(bodyBytes, &identities); err != nil {
-		return nil, errors.New("Failed to unmarshal JSON")
+func FetchIdentitiesActivity(ctx context.Context) ([]map[string]interface{}, error) {
+	identities, err := utils.ListIdentities[map[string]interface{}]()
+	if err != nil {
+		return nil, errors.New("Failed to fetch identities")
 	}
 	return identities, nil
 }
@@ -123,10 +131,43 @@ func (a *CasbinActivities) AddCasbinPolicyActivity(ctx context.Context, input mo
 			return false, errors.New("User already exist")
 		}
 	}
-	added, err := a.Enforcer.AddGroupingPolicy(input.UserId, "invite", input.OrgId)
+	added, err := policy.AddGroupingPolicy(a.Enforcer, input.UserId, "invite", input.OrgId)
 	fmt.Println(added, input, err)
 	if err != nil || !added {
 		return false, err
 	}
 	return added, nil
 }
+
+// RemoveInviteActivity undoes AddCasbinPolicyActivity when the invite could
+// not be recorded, so the user can be invited again.
+func (a *CasbinActivities) RemoveInviteActivity(ctx context.Context, input models.AddCasbinPolicy) (bool, error) {
+	_, err := a.Enforcer.DeleteRoleForUserInDomain(input.UserId, "invite", input.OrgId)
+	return err == nil, err
+}
+
+func (a *CasbinActivities) SyncPolicyActivity(ctx context.Context, input models.PolicySyncInput) (models.PolicySyncResult, error) {
+	return policy.Sync(ctx, a.Enforcer, input)
+}
+
+func RecordInviteActivity(ctx context.Context, invite models.Invite) (bool, error) {
+	invite.Status = "pending"
+	invite.CreatedAt = time.Now()
+	res, err := db.GetInviteCollection().UpdateOne(ctx,
+		bson.M{"org_id": invite.OrgID, "user_id": invite.UserID, "status": "pending"},
+		bson.M{"$setOnInsert": invite},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return false, err
+	}
+	// CheckOrgLimitActivity ran before the insert; undo it if a concurrent
+	// invite took the last slot in the meantime.
+	if res.UpsertedID != nil {
+		if err := plans.Recheck(ctx, invite.OrgID, plans.LimitPendingInvites); err != nil {
+			_, _ = db.GetInviteCollection().DeleteOne(ctx, bson.M{"_id": res.UpsertedID})
+			return false, limitFailure(err)
+		}
+	}
+	return true, nil
+}
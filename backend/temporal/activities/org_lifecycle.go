@@ -0,0 +1,194 @@
+package activities
+
+import (
+	"backend/db"
+	"backend/logostore"
+	"backend/models"
+	"backend/utils"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IsWriteRule reports whether a p rule lets someone change the org. Restore
+// and delete stay open so an archived org can be brought back, or a failed
+// purge retried. Project scope rules are only consulted behind org routes, so
+// freezing those is enough.
+func IsWriteRule(rule []string, orgID string) bool {
+	if len(rule) < 4 || rule[2] == "/orgs/restore/"+orgID || rule[2] == "/orgs/delete/"+orgID || strings.HasPrefix(rule[2], "project:") {
+		return false
+	}
+	return rule[3] != "GET" || rule[2] == "/orgs/accept/"+orgID
+}
+
+// ArchiveOrgActivity marks the org archived and records when it will be
+// purged.
+func ArchiveOrgActivity(ctx context.Context, input models.DeleteOrgInput) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(input.OrgId)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	_, err = db.GetOrgCollection().UpdateOne(ctx,
+		bson.M{"_id": objID, "archived_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"archived_at": now,
+			"archived_by": input.RequestedBy,
+			"purge_at":    now.Add(input.RetentionWindow),
+		}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FreezeOrgPoliciesActivity makes the org domain read-only by moving its
+// write rules onto the org document, from where restore puts them back.
+func (a *CasbinActivities) FreezeOrgPoliciesActivity(ctx context.Context, orgID string) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(orgID)
+	if err != nil {
+		return false, err
+	}
+	_ = a.Enforcer.LoadPolicy()
+	rules, err := a.Enforcer.GetFilteredPolicy(1, orgID)
+	if err != nil {
+		return false, err
+	}
+	var frozen [][]string
+	for _, rule := range rules {
+		if IsWriteRule(rule, orgID) {
+			frozen = append(frozen, rule)
+		}
+	}
+	if len(frozen) == 0 {
+		return true, nil
+	}
+
+	_, err = db.GetOrgCollection().UpdateOne(ctx, bson.M{"_id": objID},
+		bson.M{"$addToSet": bson.M{"frozen_policies": bson.M{"$each": frozen}}})
+	if err != nil {
+		return false, err
+	}
+	if _, err := a.Enforcer.RemovePolicies(frozen); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RestoreOrgActivity puts the frozen rules back and clears the archive
+// markers.
+func (a *CasbinActivities) RestoreOrgActivity(ctx context.Context, orgID string) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(orgID)
+	if err != nil {
+		return false, err
+	}
+	var org models.Organization
+	if err := db.GetOrgCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&org); err != nil {
+		return false, err
+	}
+
+	_ = a.Enforcer.LoadPolicy()
+	var missing [][]string
+	for _, rule := range org.FrozenPolicies {
+		if ok, _ := a.Enforcer.HasPolicy(rule); !ok {
+			missing = append(missing, rule)
+		}
+	}
+	if len(missing) > 0 {
+		if _, err := a.Enforcer.AddPolicies(missing); err != nil {
+			return false, err
+		}
+	}
+
+	_, err = db.GetOrgCollection().UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$unset": bson.M{
+		"archived_at":     "",
+		"archived_by":     "",
+		"purge_at":        "",
+		"frozen_policies": "",
+	}})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func NotifyOrgArchivedActivity(ctx context.Context, input models.DeleteOrgInput) (bool, error) {
+	for _, member := range input.Members {
+		err := utils.TriggerNotification(member.Email, "org-archived-notification", map[string]interface{}{
+			"orgId":   input.OrgId,
+			"orgName": input.OrgName,
+			"purgeIn": input.RetentionWindow.String(),
+		})
+		if err != nil {
+			fmt.Printf("Warning: failed to notify %s about org archival: %v\n", member.Email, err)
+		}
+	}
+	return true, nil
+}
+
+// DeleteOrgPoliciesActivity removes every p and g rule in the org domain.
+// Pending invites are "invite" grouping rules, so they go with it.
+func (a *CasbinActivities) DeleteOrgPoliciesActivity(ctx context.Context, orgID string) (bool, error) {
+	_ = a.Enforcer.LoadPolicy()
+	if _, err := a.Enforcer.RemoveFilteredPolicy(1, orgID); err != nil {
+		return false, err
+	}
+	if _, err := a.Enforcer.RemoveFilteredGroupingPolicy(2, orgID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteOrgResourcesActivity removes documents in other collections that
+// belong to the org.
+func DeleteOrgResourcesActivity(ctx context.Context, orgID string) (bool, error) {
+	for _, collection := range db.GetOrgScopedCollections() {
+		if _, err := collection.DeleteMany(ctx, bson.M{"org_id": orgID}); err != nil {
+			return false, fmt.Errorf("failed to clean up %s: %w", collection.Name(), err)
+		}
+	}
+	if _, err := db.GetLastSeenCollection().DeleteMany(ctx, bson.M{"domain": orgID}); err != nil {
+		return false, fmt.Errorf("failed to clean up last_seen: %w", err)
+	}
+	if objID, err := primitive.ObjectIDFromHex(orgID); err == nil {
+		var org models.Organization
+		err := db.GetOrgCollection().FindOne(ctx, bson.M{"_id": objID}).Decode(&org)
+		if err == nil && org.Settings != nil && org.Settings.Branding.LogoKey != "" {
+			err := logostore.Delete(ctx, org.Settings.Branding.LogoKey)
+			if err != nil && !errors.Is(err, logostore.ErrNotFound) {
+				return false, fmt.Errorf("failed to delete logo: %w", err)
+			}
+		}
+	}
+	return true, nil
+}
+
+func DeleteOrgDocumentActivity(ctx context.Context, orgID string) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(orgID)
+	if err != nil {
+		return false, err
+	}
+	if _, err := db.GetOrgCollection().DeleteOne(ctx, bson.M{"_id": objID}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func NotifyOrgDeletedActivity(ctx context.Context, input models.DeleteOrgInput) (bool, error) {
+	for _, member := range input.Members {
+		err := utils.TriggerNotification(member.Email, "org-deleted-notification", map[string]interface{}{
+			"orgId":   input.OrgId,
+			"orgName": input.OrgName,
+		})
+		if err != nil {
+			fmt.Printf("Warning: failed to notify %s about org deletion: %v\n", member.Email, err)
+		}
+	}
+	return true, nil
+}
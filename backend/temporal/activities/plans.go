@@ -0,0 +1,39 @@
+package activities
+
+import (
+	"backend/db"
+	"backend/models"
+	"backend/plans"
+	"context"
+	"errors"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// CheckOrgLimitActivity re-checks a plan limit inside a workflow. Hitting the
+// limit is not retried.
+func CheckOrgLimitActivity(ctx context.Context, input models.OrgLimitCheck) (bool, error) {
+	if err := plans.Check(ctx, input.OrgID, input.Limit); err != nil {
+		return false, limitFailure(err)
+	}
+	return true, nil
+}
+
+// limitFailure makes a *plans.LimitError non-retryable and passes other
+// errors through.
+func limitFailure(err error) error {
+	var limitErr *plans.LimitError
+	if errors.As(err, &limitErr) {
+		return temporal.NewNonRetryableApplicationError(limitErr.Error(), "LimitExceeded", nil)
+	}
+	return err
+}
+
+func RecordRepoActivity(ctx context.Context, repo models.Repo) (bool, error) {
+	repo.CreatedAt = time.Now()
+	if _, err := db.GetRepoCollection().InsertOne(ctx, repo); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,33 @@
+package activities
+
+import (
+	"backend/models"
+	"backend/secrets"
+	"backend/utils"
+	"context"
+	"errors"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// ReadOrgSecretActivity decrypts an org secret for a workflow. The read is
+// audited under the workflow ID; a missing secret is not retried.
+func ReadOrgSecretActivity(ctx context.Context, input models.ReadSecretInput) (string, error) {
+	secret, value, err := secrets.Get(ctx, input.OrgID, input.Name, input.Version)
+	if errors.Is(err, secrets.ErrNotFound) {
+		return "", temporal.NewNonRetryableApplicationError(err.Error(), "SecretNotFound", nil)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_ = utils.RecordAudit(ctx, models.AuditEntry{
+		Action:   "org.secret_read",
+		ActorID:  "workflow:" + activity.GetInfo(ctx).WorkflowExecution.ID,
+		TargetID: input.Name,
+		Domain:   input.OrgID,
+		Details:  map[string]interface{}{"version": secret.Version},
+	})
+	return value, nil
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"backend/db"
 	"backend/middleware"
+	"backend/policy"
 	"backend/temporal/activities"
 	"backend/temporal/workflows"
 	"log"
+	"os"
 
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
@@ -24,14 +27,27 @@ func main() {
 	if err != nil {
 		log.Fatalf("unable to create Temporal client: %v", err)
 	}
+	if err := db.ConnectDB("mongodb://localhost:27017"); err != nil {
+		log.Fatalf("unable to connect to MongoDB: %v", err)
+	}
 	w1 := worker.New(c, "CREATE_REPO_QUEUE", worker.Options{})
 	w1.RegisterWorkflow(workflows.CreateRepoWorkflow)
 	w1.RegisterActivity(activities.CreateRepoActivity)
+	w1.RegisterActivity(activities.CheckOrgLimitActivity)
+	w1.RegisterActivity(activities.RecordRepoActivity)
+	w1.RegisterActivity(activities.ReadOrgSecretActivity)
 
 	enforcer, err := middleware.InitCasbin()
 	if err != nil {
 		log.Fatalf("Casbin init failed: %v", err)
 	}
+	policyDir := os.Getenv("POLICY_DIR")
+	if policyDir == "" {
+		policyDir = "../policies"
+	}
+	if err := policy.LoadConstraints(policyDir); err != nil {
+		log.Printf("Warning: failed to load separation-of-duties constraints: %v", err)
+	}
 	casbinActivities := &activities.CasbinActivities{
 		Enforcer: enforcer,
 	}
@@ -42,8 +58,32 @@ func main() {
 	w2.RegisterActivity(activities.FetchIdentitiesActivity)
 	w2.RegisterActivity(activities.FindIdentityByEmailActivity)
 	w2.RegisterActivity(activities.CheckSelfInviteActivity)
+	w2.RegisterActivity(activities.RecordInviteActivity)
+	w2.RegisterActivity(activities.CheckOrgLimitActivity)
 	w2.RegisterActivity(casbinActivities)
 
+	w3 := worker.New(c, "POLICY_SYNC_QUEUE", worker.Options{})
+	w3.RegisterWorkflow(workflows.PolicySyncWorkflow)
+	w3.RegisterActivity(casbinActivities)
+
+	w4 := worker.New(c, "BREAK_GLASS_QUEUE", worker.Options{})
+	w4.RegisterWorkflow(workflows.BreakGlassWorkflow)
+	w4.RegisterActivity(activities.BreakGlassAlertActivity)
+	w4.RegisterActivity(casbinActivities)
+
+	w5 := worker.New(c, "ORG_LIFECYCLE_QUEUE", worker.Options{})
+	w5.RegisterWorkflow(workflows.DeleteOrgWorkflow)
+	w5.RegisterActivity(activities.DeleteOrgResourcesActivity)
+	w5.RegisterActivity(activities.DeleteOrgDocumentActivity)
+	w5.RegisterActivity(activities.NotifyOrgDeletedActivity)
+	w5.RegisterActivity(activities.ArchiveOrgActivity)
+	w5.RegisterActivity(activities.NotifyOrgArchivedActivity)
+	w5.RegisterActivity(activities.ReadOrgSecretActivity)
+	w5.RegisterActivity(casbinActivities)
+
+	w6 := worker.New(c, "BULK_IMPORT_QUEUE", worker.Options{})
+	w6.RegisterWorkflow(workflows.BulkImportWorkflow)
+
 	go func() {
 		err := w1.Run(worker.InterruptCh())
 		if err != nil {
@@ -58,6 +98,34 @@ func main() {
 		}
 	}()
 
+	go func() {
+		err := w3.Run(worker.InterruptCh())
+		if err != nil {
+			log.Fatal("unable to start worker 3:", err)
+		}
+	}()
+
+	go func() {
+		err := w4.Run(worker.InterruptCh())
+		if err != nil {
+			log.Fatal("unable to start worker 4:", err)
+		}
+	}()
+
+	go func() {
+		err := w5.Run(worker.InterruptCh())
+		if err != nil {
+			log.Fatal("unable to start worker 5:", err)
+		}
+	}()
+
+	go func() {
+		err := w6.Run(worker.InterruptCh())
+		if err != nil {
+			log.Fatal("unable to start worker 6:", err)
+		}
+	}()
+
 	select {}
 
 	// c.Close()
@@ -0,0 +1,63 @@
+package workflows
+
+import (
+	"backend/models"
+	"backend/temporal/activities"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+func BreakGlassWorkflow(ctx workflow.Context, input models.BreakGlassInput) (bool, error) {
+	opts := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval: time.Second * 1,
+			MaximumAttempts: 2,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, opts)
+	logger := workflow.GetLogger(ctx)
+	var done bool
+
+	err := workflow.ExecuteActivity(ctx, "GrantBreakGlassActivity", input).Get(ctx, &done)
+	if err != nil {
+		_ = workflow.ExecuteActivity(ctx, activities.BreakGlassAlertActivity, "rejected", input).Get(ctx, &done)
+		return false, err
+	}
+
+	// From here on the grant exists, so failures must not stop the workflow
+	// before it reaches the revocation.
+	err = workflow.ExecuteActivity(ctx, activities.BreakGlassAlertActivity, "granted", input).Get(ctx, &done)
+	if err != nil {
+		logger.Error("break-glass grant alert failed", "error", err)
+	}
+
+	if err := workflow.Sleep(ctx, input.Duration); err != nil {
+		logger.Error("break-glass timer interrupted", "error", err)
+	}
+
+	// A cancelled workflow must still revoke the grant, so the cleanup runs on
+	// a context the cancellation doesn't reach.
+	cleanupCtx, _ := workflow.NewDisconnectedContext(ctx)
+	revokeCtx := workflow.WithActivityOptions(cleanupCtx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second * 5,
+			MaximumInterval:    time.Minute,
+			BackoffCoefficient: 2,
+			MaximumAttempts:    0,
+		},
+	})
+	err = workflow.ExecuteActivity(revokeCtx, "RevokeBreakGlassActivity", input).Get(revokeCtx, &done)
+	if err != nil {
+		return false, err
+	}
+
+	err = workflow.ExecuteActivity(cleanupCtx, activities.BreakGlassAlertActivity, "expired", input).Get(cleanupCtx, &done)
+	if err != nil {
+		logger.Error("break-glass expiry alert failed", "error", err)
+	}
+	return true, nil
+}
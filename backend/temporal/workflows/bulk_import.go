@@ -0,0 +1,87 @@
+package workflows
+
+import (
+	"backend/models"
+	"errors"
+	"fmt"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// BulkImportProgressQuery returns the import's models.BulkImportProgress.
+const BulkImportProgressQuery = "progress"
+
+// bulkImportBatch is how many invites run at once.
+const bulkImportBatch = 10
+
+// BulkImportWorkflow invites every row of a member import as a child
+// NovuInviteWorkflow, a batch at a time. A failed row is recorded without
+// stopping the rest of the import. Rows are not rerun as a whole, since the
+// invite steps refuse a user who is already invited; the child's activities
+// do their own retries.
+func BulkImportWorkflow(ctx workflow.Context, input models.BulkImportInput) (models.BulkImportProgress, error) {
+	progress := models.BulkImportProgress{
+		ImportID: input.ImportID,
+		Total:    len(input.Rows),
+		Results:  make([]models.BulkImportRowResult, len(input.Rows)),
+	}
+	for i, row := range input.Rows {
+		progress.Results[i] = models.BulkImportRowResult{BulkImportRow: row, Status: "pending"}
+	}
+	err := workflow.SetQueryHandler(ctx, BulkImportProgressQuery, func() (models.BulkImportProgress, error) {
+		return progress, nil
+	})
+	if err != nil {
+		return progress, err
+	}
+
+	workflowID := workflow.GetInfo(ctx).WorkflowExecution.ID
+	for start := 0; start < len(input.Rows); start += bulkImportBatch {
+		end := min(start+bulkImportBatch, len(input.Rows))
+
+		futures := make([]workflow.ChildWorkflowFuture, 0, end-start)
+		for _, row := range input.Rows[start:end] {
+			childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+				WorkflowID: fmt.Sprintf("%s-row-%d", workflowID, row.Row),
+				TaskQueue:  "NOVU_INVITE_QUEUE",
+			})
+			futures = append(futures, workflow.ExecuteChildWorkflow(childCtx, NovuInviteWorkflow, models.CreateInvite{
+				OrgID:       input.OrgID,
+				OrgName:     input.OrgName,
+				Email:       row.Email,
+				Description: input.Description,
+				UserId:      input.RequestedBy,
+				Role:        row.Role,
+				TeamID:      row.TeamID,
+			}))
+		}
+
+		for i, future := range futures {
+			result := &progress.Results[start+i]
+			var done bool
+			if err := future.Get(ctx, &done); err != nil {
+				result.Status = "failed"
+				result.Error = failureMessage(err)
+				progress.Failed++
+			} else {
+				result.Status = "invited"
+				progress.Succeeded++
+			}
+			progress.Processed++
+		}
+	}
+
+	progress.Done = true
+	return progress, nil
+}
+
+// failureMessage digs the activity's own message out of a child workflow
+// failure.
+func failureMessage(err error) string {
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) {
+		return appErr.Message()
+	}
+	return err.Error()
+}
@@ -2,6 +2,7 @@ package workflows
 
 import (
 	"backend/models"
+	"backend/plans"
 	"backend/temporal/activities"
 	"time"
 
@@ -44,6 +45,13 @@ func NovuInviteWorkflow(ctx workflow.Context, input models.CreateInvite) (bool,
 	if err != nil {
 		return false, err
 	}
+	for _, limit := range []string{plans.LimitPendingInvites, plans.LimitMembers} {
+		check := models.OrgLimitCheck{OrgID: input.OrgID, Limit: limit}
+		err = workflow.ExecuteActivity(ctx, activities.CheckOrgLimitActivity, check).Get(ctx, &done)
+		if err != nil {
+			return false, err
+		}
+	}
 	Casbin := models.AddCasbinPolicy{
 		UserId: id,
 		OrgId:  input.OrgID,
@@ -52,6 +60,23 @@ func NovuInviteWorkflow(ctx workflow.Context, input models.CreateInvite) (bool,
 	if err != nil {
 		return false, err
 	}
+	role := input.Role
+	if role == "" {
+		role = "reader"
+	}
+	Invite := models.Invite{
+		OrgID:     input.OrgID,
+		UserID:    id,
+		Email:     input.Email,
+		Role:      role,
+		TeamID:    input.TeamID,
+		InvitedBy: input.UserId,
+	}
+	err = workflow.ExecuteActivity(ctx, activities.RecordInviteActivity, Invite).Get(ctx, &done)
+	if err != nil {
+		_ = workflow.ExecuteActivity(ctx, "RemoveInviteActivity", Casbin).Get(ctx, nil)
+		return false, err
+	}
 	err = workflow.ExecuteActivity(ctx, activities.SendInviteNotificationActivity, input).Get(ctx, &done)
 	if err != nil {
 		return done, err
@@ -2,6 +2,7 @@ package workflows
 
 import (
 	"backend/models"
+	"backend/plans"
 	"backend/temporal/activities"
 	"time"
 
@@ -19,10 +20,33 @@ func CreateRepoWorkflow(ctx workflow.Context, input models.CreateRepoInput) (*gi
 		},
 	}
 	ctx = workflow.WithActivityOptions(ctx, opts)
+	var done bool
+	if input.OrgID != "" {
+		check := models.OrgLimitCheck{OrgID: input.OrgID, Limit: plans.LimitRepos}
+		err := workflow.ExecuteActivity(ctx, activities.CheckOrgLimitActivity, check).Get(ctx, &done)
+		if err != nil {
+			return nil, err
+		}
+	}
 	var repo *github.Repository
 	err := workflow.ExecuteActivity(ctx, activities.CreateRepoActivity, input).Get(ctx, &repo)
 	if err != nil {
 		return nil, err
 	}
+	if input.OrgID != "" {
+		record := models.Repo{
+			OrgID:     input.OrgID,
+			ProjectID: input.ProjectID,
+			Name:      repo.GetName(),
+			FullName:  repo.GetFullName(),
+			URL:       repo.GetHTMLURL(),
+			Private:   repo.GetPrivate(),
+			CreatedBy: input.CreatedBy,
+		}
+		err = workflow.ExecuteActivity(ctx, activities.RecordRepoActivity, record).Get(ctx, &done)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return repo, nil
 }
@@ -0,0 +1,92 @@
+package workflows
+
+import (
+	"backend/models"
+	"backend/temporal/activities"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// RestoreOrgSignal cancels a pending purge and brings the org back.
+const RestoreOrgSignal = "restore-org"
+
+// DeleteOrgWorkflow archives an organization and freezes its domain, then
+// waits out the retention window. A restore signal before the timer fires
+// undoes the archive; otherwise the org is torn down. Every step is
+// idempotent, so rerunning the workflow after a partial failure finishes the
+// job.
+func DeleteOrgWorkflow(ctx workflow.Context, input models.DeleteOrgInput) (bool, error) {
+	opts := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval: time.Second * 1,
+			MaximumAttempts: 5,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, opts)
+	var done bool
+
+	err := workflow.ExecuteActivity(ctx, activities.ArchiveOrgActivity, input).Get(ctx, &done)
+	if err != nil {
+		return false, err
+	}
+	err = workflow.ExecuteActivity(ctx, "FreezeOrgPoliciesActivity", input.OrgId).Get(ctx, &done)
+	if err != nil {
+		return false, err
+	}
+	if !input.Resumed {
+		err = workflow.ExecuteActivity(ctx, activities.NotifyOrgArchivedActivity, input).Get(ctx, &done)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	restored := false
+	timerCtx, cancelTimer := workflow.WithCancel(ctx)
+	selector := workflow.NewSelector(ctx)
+	selector.AddFuture(workflow.NewTimer(timerCtx, input.RetentionWindow), func(f workflow.Future) {})
+	selector.AddReceive(workflow.GetSignalChannel(ctx, RestoreOrgSignal), func(c workflow.ReceiveChannel, more bool) {
+		var signal models.RestoreOrgSignal
+		c.Receive(ctx, &signal)
+		restored = true
+		cancelTimer()
+	})
+	selector.Select(ctx)
+
+	if restored {
+		err = workflow.ExecuteActivity(ctx, "RestoreOrgActivity", input.OrgId).Get(ctx, &done)
+		if err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	// The teardown steps retry until they succeed. Once the rules are gone
+	// nobody can reach the delete route to start another run, and the rules
+	// must go before the document so none are left without an org.
+	purgeOpts := opts
+	purgeOpts.RetryPolicy = &temporal.RetryPolicy{
+		InitialInterval: time.Second * 1,
+		MaximumInterval: time.Minute * 10,
+	}
+	purgeCtx := workflow.WithActivityOptions(ctx, purgeOpts)
+	err = workflow.ExecuteActivity(purgeCtx, activities.DeleteOrgResourcesActivity, input.OrgId).Get(ctx, &done)
+	if err != nil {
+		return false, err
+	}
+	err = workflow.ExecuteActivity(purgeCtx, "DeleteOrgPoliciesActivity", input.OrgId).Get(ctx, &done)
+	if err != nil {
+		return false, err
+	}
+	err = workflow.ExecuteActivity(purgeCtx, activities.DeleteOrgDocumentActivity, input.OrgId).Get(ctx, &done)
+	if err != nil {
+		return false, err
+	}
+	err = workflow.ExecuteActivity(ctx, activities.NotifyOrgDeletedActivity, input).Get(ctx, &done)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,26 @@
+package workflows
+
+import (
+	"backend/models"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+func PolicySyncWorkflow(ctx workflow.Context, input models.PolicySyncInput) (models.PolicySyncResult, error) {
+	opts := workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval: time.Second * 5,
+			MaximumAttempts: 3,
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, opts)
+	var result models.PolicySyncResult
+	err := workflow.ExecuteActivity(ctx, "SyncPolicyActivity", input).Get(ctx, &result)
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"backend/db"
+	"backend/models"
+	"context"
+	"time"
+)
+
+func RecordAudit(ctx context.Context, entry models.AuditEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	_, err := db.GetAuditCollection().InsertOne(ctx, entry)
+	return err
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"backend/models"
+	"context"
+	"log"
+	"os"
+	"strings"
+)
+
+// BreakGlassAlert makes every use of the emergency credential impossible to
+// miss: it is logged, written to the audit log and sent to everyone listed in
+// BREAK_GLASS_NOTIFY.
+func BreakGlassAlert(ctx context.Context, event string, input models.BreakGlassInput) error {
+	log.Printf("!!! BREAK-GLASS %s: user=%s email=%s reason=%q duration=%s",
+		strings.ToUpper(event), input.UserId, input.Email, input.Reason, input.Duration)
+
+	err := RecordAudit(ctx, models.AuditEntry{
+		Action:   "break_glass." + event,
+		ActorID:  input.UserId,
+		TargetID: input.UserId,
+		Domain:   "main",
+		Details: map[string]interface{}{
+			"email":    input.Email,
+			"reason":   input.Reason,
+			"duration": input.Duration.String(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, recipient := range strings.Split(os.Getenv("BREAK_GLASS_NOTIFY"), ",") {
+		recipient = strings.TrimSpace(recipient)
+		if recipient == "" {
+			continue
+		}
+		err := TriggerNotification(recipient, "break-glass-alert", map[string]interface{}{
+			"event":    event,
+			"userId":   input.UserId,
+			"email":    input.Email,
+			"reason":   input.Reason,
+			"duration": input.Duration.String(),
+		})
+		if err != nil {
+			log.Printf("Warning: failed to send break-glass alert to %s: %v", recipient, err)
+		}
+	}
+	return nil
+}
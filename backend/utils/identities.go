@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	kratosIdentitiesURL = "http://localhost:4434/admin/identities"
+	identitiesPageSize  = "250"
+)
+
+// ListIdentities returns every Kratos identity. Kratos pages the list, so
+// this follows the rel="next" links until the last page.
+func ListIdentities[T any]() ([]T, error) {
+	base, _ := url.Parse(kratosIdentitiesURL)
+	next := kratosIdentitiesURL + "?page_size=" + identitiesPageSize
+
+	var all []T
+	seen := map[string]bool{}
+	for next != "" && !seen[next] {
+		seen[next] = true
+
+		resp, err := http.Get(next)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to Kratos: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("kratos returned %s", resp.Status)
+		}
+		var page []T
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode identities: %w", err)
+		}
+		all = append(all, page...)
+		if len(page) == 0 {
+			break
+		}
+		next = nextPage(base, resp.Header.Get("Link"))
+	}
+	return all, nil
+}
+
+// nextPage extracts the rel="next" target from a Link header, resolved
+// against base. Kratos sends relative links.
+func nextPage(base *url.URL, header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 {
+			continue
+		}
+		isNext := false
+		for _, param := range parts[1:] {
+			if strings.ReplaceAll(strings.TrimSpace(param), `"`, "") == "rel=next" {
+				isNext = true
+			}
+		}
+		if !isNext {
+			continue
+		}
+		target, err := url.Parse(strings.Trim(strings.TrimSpace(parts[0]), "<>"))
+		if err != nil {
+			return ""
+		}
+		return base.ResolveReference(target).String()
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestNextPage(t *testing.T) {
+	base, _ := url.Parse(kratosIdentitiesURL)
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{
+			name:   "no header",
+			header: "",
+			want:   "",
+		},
+		{
+			name:   "relative next link",
+			header: `</admin/identities?page_size=250&page_token=abc>; rel="next"`,
+			want:   "http://localhost:4434/admin/identities?page_size=250&page_token=abc",
+		},
+		{
+			name:   "absolute next link",
+			header: `<http://kratos:4434/admin/identities?page_token=abc>; rel="next"`,
+			want:   "http://kratos:4434/admin/identities?page_token=abc",
+		},
+		{
+			name:   "next among other links",
+			header: `</admin/identities?page_token=first>; rel="first", </admin/identities?page_token=xyz>; rel="next"`,
+			want:   "http://localhost:4434/admin/identities?page_token=xyz",
+		},
+		{
+			name:   "unquoted rel",
+			header: `</admin/identities?page_token=abc>; rel=next`,
+			want:   "http://localhost:4434/admin/identities?page_token=abc",
+		},
+		{
+			name:   "last page",
+			header: `</admin/identities?page_token=first>; rel="first"`,
+			want:   "",
+		},
+		{
+			name:   "link without params",
+			header: `</admin/identities?page_token=abc>`,
+			want:   "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextPage(base, tt.header); got != tt.want {
+				t.Errorf("nextPage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// FetchNotifications returns the latest entries of a subscriber's Novu
+// in-app feed.
+func FetchNotifications(ctx context.Context, subscriberID string, limit int) ([]map[string]interface{}, error) {
+	apiKey := os.Getenv("NOVU_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("NOVU_API_KEY not set")
+	}
+
+	endpoint := "https://api.novu.co/v1/subscribers/" + url.PathEscape(subscriberID) +
+		"/notifications/feed?limit=" + strconv.Itoa(limit)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "ApiKey "+apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("failed to fetch Novu notifications: %s", resp.Status)
+	}
+
+	var feed struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, err
+	}
+	return feed.Data, nil
+}
@@ -15,6 +15,14 @@ type NovuPayload struct {
 }
 
 func TriggerInviteAcceptedNotification(email, orgID, orgName string) error {
+	return TriggerNotification(email, "org-invite-notification", map[string]interface{}{
+		"orgId":    orgID,
+		"orgName":  orgName,
+		"accepted": true,
+	})
+}
+
+func TriggerNotification(subscriberID, name string, data map[string]interface{}) error {
 	apiKey := os.Getenv("NOVU_API_KEY")
 	if apiKey == "" {
 		return fmt.Errorf("NOVU_API_KEY not set")
@@ -22,14 +30,10 @@ func TriggerInviteAcceptedNotification(email, orgID, orgName string) error {
 
 	payload := NovuPayload{
 		To: map[string]interface{}{
-			"subscriberId": email,
-		},
-		Name: "org-invite-notification",
-		Payload: map[string]interface{}{
-			"orgId":    orgID,
-			"orgName":  orgName,
-			"accepted": true,
+			"subscriberId": subscriberID,
 		},
+		Name:    name,
+		Payload: data,
 	}
 
 	body, err := json.Marshal(payload)
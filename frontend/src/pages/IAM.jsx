@@ -22,7 +22,7 @@ function IAM() {
         }
 
         const data = await response.json();
-        setOrganizations(data); 
+        setOrganizations(data.data || []);
       } catch (error) {
         console.error("Error fetching orgs:", error.message);
       } finally {
@@ -188,7 +188,7 @@ function IAM() {
                 </p>
                 <div className="flex justify-end">
                   <button
-                    onClick={() => handleViewOrg(org.id)}
+                    onClick={() => handleViewOrg(org.slug || org.id)}
                     className="px-4 py-2 rounded-lg transition-colors"
                     style={{ 
                       backgroundColor: COLORS.backgroundSecondary,
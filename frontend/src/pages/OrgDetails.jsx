@@ -7,6 +7,7 @@ import { FiArrowLeft, FiUser, FiMail, FiShield, FiPlus, FiX, FiSend } from 'reac
 const OrgDetails = () => {
   const { orgId } = useParams();
   const [org, setOrg] = useState(null);
+  const [members, setMembers] = useState([]);
   const [role, setRole] = useState("");
   const [user, setUser] = useState(null);
   const [inviteEmail, setInviteEmail] = useState('');
@@ -51,6 +52,14 @@ const OrgDetails = () => {
         setOrg(data.org);
         setRole(data.role);
         setUser(data.user);
+
+        const membersRes = await fetch(`http://localhost:8080/orgs/members/${orgId}?limit=100`, {
+          credentials: 'include',
+        });
+        if (!membersRes.ok) throw new Error('Failed to fetch organization members');
+
+        const membersData = await membersRes.json();
+        setMembers(membersData.data || []);
       } catch (error) {
         console.error('Error:', error);
         await showErrorAlert(error.message);
@@ -74,7 +83,7 @@ const OrgDetails = () => {
         headers: { 'Content-Type': 'application/json' },
         credentials: 'include',
         body: JSON.stringify({ 
-          org_id: orgId,
+          org_id: org.id,
           org_name: org.name, 
           email: inviteEmail,
           description: org.description 
@@ -112,12 +121,9 @@ const OrgDetails = () => {
       });
       if (!res.ok) throw new Error('Failed to update role');
 
-      setOrg(prev => ({
-        ...prev,
-        Users: prev.Users.map(u =>
-          u.id === userId ? { ...u, role: newRole } : u
-        )
-      }));
+      setMembers(prev => prev.map(u =>
+        u.id === userId ? { ...u, role: newRole } : u
+      ));
 
       await Swal.fire({
         icon: 'success',
@@ -279,7 +285,7 @@ const OrgDetails = () => {
         )}
 
         <section className="space-y-4">
-          {!Array.isArray(org.Users) || org.Users?.length === 0 ? (
+          {members.length === 0 ? (
             <div 
               className="text-center py-10 border-t rounded-lg"
               style={{ 
@@ -292,7 +298,7 @@ const OrgDetails = () => {
             </div>
           ) : (
             <div className="grid gap-4">
-              {org.Users?.map((identity) => (
+              {members.map((identity) => (
                 <UserCard
                   key={identity.id}
                   identity={identity}
@@ -20,7 +20,7 @@ function Organization() {
         }
 
         const data = await response.json();
-        setOrganizations(data); 
+        setOrganizations(data.data || []);
       } catch (error) {
         console.error("Error fetching orgs:", error.message);
         Swal.fire({
@@ -128,7 +128,7 @@ function Organization() {
                 </p>
                 <div className="flex justify-end">
                   <button
-                    onClick={() => handleViewOrg(org.id)}
+                    onClick={() => handleViewOrg(org.slug || org.id)}
                     className="px-4 py-2 rounded-lg transition-colors"
                     style={{ 
                       backgroundColor: COLORS.backgroundSecondary,